@@ -0,0 +1,169 @@
+package vlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Overflow is the policy of an async Logger when its queue is full.
+type Overflow int
+
+const (
+	// OverflowBlock blocks the logging goroutine until the queue has room.
+	OverflowBlock Overflow = iota
+	// OverflowDropNewest drops the record being logged.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest record in the queue.
+	OverflowDropOldest
+)
+
+// AsyncOptions configures the Logger returned by NewAsyncLogger.
+type AsyncOptions struct {
+	// QueueSize is the number of records the queue holds. Default is 1024.
+	QueueSize int
+
+	// Overflow is the policy when the queue is full. Default is OverflowBlock.
+	Overflow Overflow
+}
+
+// NewAsyncLogger returns a Logger that hands the records to a bounded
+// queue, which is drained by a goroutine writing to l. A slow l, e.g.
+// a rotated file on a slow disk, does not stall the logging goroutines
+// unless the queue is full.
+//
+// The dropped records are counted, and a "N messages dropped" warning
+// is logged when the queue is drained. Flush waits until the queued
+// records are written to l, and flushes l. Close writes the queued
+// records, stops the goroutine and closes l.
+//
+// The records are formatted in the goroutine, so the values of the
+// Fields should not be modified after logging.
+func NewAsyncLogger(l Logger, opts AsyncOptions) Logger {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	al := &asyncLogger{
+		lg:       l,
+		overflow: opts.Overflow,
+		ch:       make(chan *Record, opts.QueueSize),
+		flushc:   make(chan chan struct{}),
+		reopenc:  make(chan chan error),
+		closec:   make(chan chan error),
+		done:     make(chan struct{}),
+	}
+	go al.writeloop()
+	return al
+}
+
+type asyncLogger struct {
+	lg       Logger
+	overflow Overflow
+	ch       chan *Record
+	flushc   chan chan struct{}
+	reopenc  chan chan error
+	closec   chan chan error
+	done     chan struct{} // closed when writeloop exits
+	dropped  uint64        // since the last report
+}
+
+func (al *asyncLogger) Log(r *Record) {
+	switch al.overflow {
+	case OverflowDropNewest:
+		select {
+		case al.ch <- r:
+		default:
+			atomic.AddUint64(&al.dropped, 1)
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case al.ch <- r:
+				return
+			default:
+			}
+			select {
+			case <-al.ch:
+				atomic.AddUint64(&al.dropped, 1)
+			default:
+			}
+		}
+
+	default:
+		select {
+		case al.ch <- r:
+		case <-al.done: // closed
+		}
+	}
+}
+
+func (al *asyncLogger) Flush() {
+	done := make(chan struct{})
+	select {
+	case al.flushc <- done:
+		<-done
+	case <-al.done: // closed
+	}
+}
+
+// Reopen reopens l after the queued records are written to l.
+func (al *asyncLogger) Reopen() error {
+	ec := make(chan error)
+	select {
+	case al.reopenc <- ec:
+		return <-ec
+	case <-al.done: // closed
+		return nil
+	}
+}
+
+func (al *asyncLogger) Close() error {
+	ec := make(chan error)
+	select {
+	case al.closec <- ec:
+		return <-ec
+	case <-al.done: // closed
+		return nil
+	}
+}
+
+func (al *asyncLogger) writeloop() {
+	for {
+		select {
+		case r := <-al.ch:
+			al.lg.Log(r)
+			if len(al.ch) == 0 {
+				al.reportDropped()
+			}
+
+		case done := <-al.flushc:
+			al.drain()
+			al.lg.Flush()
+			close(done)
+
+		case ec := <-al.reopenc:
+			al.drain()
+			ec <- reopen(al.lg)
+
+		case ec := <-al.closec:
+			al.drain()
+			close(al.done)
+			ec <- al.lg.Close()
+			return
+		}
+	}
+}
+
+// drain writes the queued records.
+func (al *asyncLogger) drain() {
+	for n := len(al.ch); n > 0; n-- {
+		al.lg.Log(<-al.ch)
+	}
+	al.reportDropped()
+}
+
+func (al *asyncLogger) reportDropped() {
+	if n := atomic.SwapUint64(&al.dropped, 0); n > 0 {
+		al.lg.Log(&Record{Time: time.Now(), Level: warn, Msg: Format("%d messages dropped", n)})
+	}
+}
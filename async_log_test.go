@@ -0,0 +1,142 @@
+package vlog
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// blockLogger blocks Log until unblocked, and records the messages.
+type blockLogger struct {
+	mu       sync.Mutex
+	started  chan struct{} // Log is called
+	block    chan struct{}
+	msgs     []string
+	flushed  int
+	reopened []int // the number of the messages at each Reopen
+	closed   bool
+}
+
+func (bl *blockLogger) Log(r *Record) {
+	select {
+	case bl.started <- struct{}{}:
+	default:
+	}
+	<-bl.block
+	bl.mu.Lock()
+	bl.msgs = append(bl.msgs, r.Msg)
+	bl.mu.Unlock()
+}
+
+func (bl *blockLogger) Flush() {
+	bl.mu.Lock()
+	bl.flushed++
+	bl.mu.Unlock()
+}
+
+func (bl *blockLogger) Reopen() error {
+	bl.mu.Lock()
+	bl.reopened = append(bl.reopened, len(bl.msgs))
+	bl.mu.Unlock()
+	return nil
+}
+
+func (bl *blockLogger) Close() error {
+	bl.mu.Lock()
+	bl.closed = true
+	bl.mu.Unlock()
+	return nil
+}
+
+func TestAsyncLogger(t *testing.T) {
+	testcases := []struct {
+		overflow Overflow
+		want     []string
+	}{
+		{OverflowDropNewest, []string{"0", "1", "2", "3", "2 messages dropped"}},
+		{OverflowDropOldest, []string{"0", "3", "4", "5", "2 messages dropped"}},
+	}
+	for i, tc := range testcases {
+		bl := &blockLogger{started: make(chan struct{}, 1), block: make(chan struct{})}
+		al := NewAsyncLogger(bl, AsyncOptions{QueueSize: 3, Overflow: tc.overflow})
+		al.Log(&Record{Msg: "0"})
+		<-bl.started // the writer takes 0 and blocks
+		for j := 1; j < 6; j++ {
+			al.Log(&Record{Msg: strconv.Itoa(j)})
+		}
+		close(bl.block)
+		al.Flush()
+
+		bl.mu.Lock()
+		got := bl.msgs
+		bl.mu.Unlock()
+		if len(got) != len(tc.want) {
+			t.Fatalf("%d: got %v, want %v", i, got, tc.want)
+		}
+		for j := range got {
+			if got[j] != tc.want[j] {
+				t.Errorf("%d: got %v, want %v", i, got, tc.want)
+				break
+			}
+		}
+		if bl.flushed != 1 {
+			t.Errorf("%d: flushed got %d, want 1", i, bl.flushed)
+		}
+	}
+}
+
+func TestAsyncLoggerBlock(t *testing.T) {
+	bl := &blockLogger{block: make(chan struct{})}
+	close(bl.block)
+	al := NewAsyncLogger(bl, AsyncOptions{QueueSize: 1})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				al.Log(&Record{Msg: "m"})
+			}
+		}()
+	}
+	wg.Wait()
+	al.Flush()
+	if len(bl.msgs) != 1000 {
+		t.Errorf("got %d, want 1000", len(bl.msgs))
+	}
+}
+
+func TestAsyncLoggerClose(t *testing.T) {
+	bl := &blockLogger{block: make(chan struct{})}
+	close(bl.block)
+	al := NewAsyncLogger(bl, AsyncOptions{QueueSize: 10})
+	for i := 0; i < 5; i++ {
+		al.Log(&Record{Msg: "m"})
+	}
+	if e := al.Close(); e != nil {
+		t.Fatalf("close err=%v", e)
+	}
+	if len(bl.msgs) != 5 || !bl.closed {
+		t.Errorf("got %d msgs closed=%v, want 5 and closed", len(bl.msgs), bl.closed)
+	}
+	// noop after Close
+	al.Log(&Record{Msg: "m"})
+	al.Flush()
+	al.Close()
+}
+
+func TestAsyncLoggerReopen(t *testing.T) {
+	bl := &blockLogger{block: make(chan struct{})}
+	close(bl.block)
+	al := NewAsyncLogger(bl, AsyncOptions{QueueSize: 10})
+	defer al.Close()
+	for i := 0; i < 5; i++ {
+		al.Log(&Record{Msg: "m"})
+	}
+	if e := al.(reopener).Reopen(); e != nil {
+		t.Fatalf("reopen err=%v", e)
+	}
+	if len(bl.reopened) != 1 || bl.reopened[0] != 5 {
+		t.Errorf("reopened got %v, want after 5 msgs", bl.reopened)
+	}
+}
@@ -0,0 +1,99 @@
+package vlog
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Config configures vlog without the flags, see Init.
+type Config struct {
+	// Levels is the level setting in the format of -vlog.
+	Levels string
+
+	// File is the prefix of the log files. If File is empty,
+	// the log is written to stderr.
+	File string
+
+	// Formatter formats the records. Default is TextFormatter.
+	// If both File and Formatter are empty, the current Logger, e.g.
+	// one set by SetLogger, is kept.
+	Formatter Formatter
+
+	// Rotate configures the log files, like the rotation size MaxSize
+	// and FlushInterval. Rotate.Formatter defaults to Formatter.
+	Rotate RotateOptions
+}
+
+// Init sets the levels and the Logger from c. The previous Logger is
+// closed, unless it is kept, see Config.Formatter. If c.Levels is
+// malformed, Init returns an error and changes nothing.
+//
+// Unlike Parse, Init does not touch the flags, for a program using
+// another flag package or a library.
+func Init(c Config) error {
+	if e := setLevels(c.Levels); e != nil {
+		return e
+	}
+	if c.File == "" && c.Formatter == nil {
+		return nil
+	}
+	if c.Formatter == nil {
+		c.Formatter = TextFormatter
+	}
+	var l Logger
+	if c.File != "" {
+		if c.Rotate.Formatter == nil {
+			c.Rotate.Formatter = c.Formatter
+		}
+		l = NewRotateLogger(c.File, c.Rotate)
+	} else {
+		l = NewWriterLogger(os.Stderr, c.Formatter)
+	}
+	swapLogger(l).Close()
+	return nil
+}
+
+// RegisterFlags registers the vlog flags to fs, and returns the Config
+// set by the flags. After fs is parsed, the Config can be passed to Init.
+// The flags are,
+//
+//	-vlog: vlog settings, k=v(,k=v)*
+//	-vlogfile: vlog file prefix
+//	-vlogformat: vlog format, text|logfmt|json
+//	-vlogmaxsize: vlog file size limit in bytes
+//	-vlogflush: vlog file flush interval
+func RegisterFlags(fs *flag.FlagSet) *Config {
+	c := &Config{}
+	fs.StringVar(&c.Levels, "vlog", "", "vlog settings, k=v(,k=v)*")
+	fs.StringVar(&c.File, "vlogfile", "", "vlog file prefix")
+	fs.Var(formatFlag{&c.Formatter}, "vlogformat", "vlog format, text|logfmt|json")
+	fs.IntVar(&c.Rotate.MaxSize, "vlogmaxsize", logLimit, "vlog file size limit in bytes")
+	fs.DurationVar(&c.Rotate.FlushInterval, "vlogflush", logFlushInterval, "vlog file flush interval")
+	return c
+}
+
+// formatFlag sets a Formatter by its name in formatters.
+type formatFlag struct {
+	f *Formatter
+}
+
+func (ff formatFlag) String() string {
+	if ff.f != nil {
+		for name, f := range formatters {
+			if f == *ff.f {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func (ff formatFlag) Set(s string) error {
+	f, ok := formatters[s]
+	if !ok {
+		return fmt.Errorf("invalid format=%s", s)
+	}
+	*ff.f = f
+	return nil
+}
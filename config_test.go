@@ -0,0 +1,74 @@
+package vlog
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	c := RegisterFlags(fs)
+	if c.Formatter != nil || c.Rotate.MaxSize != logLimit {
+		t.Errorf("default got %+v", c)
+	}
+	e := fs.Parse([]string{"-vlog=*=e,a=v1", "-vlogfile=/tmp/x", "-vlogformat=json", "-vlogmaxsize=100", "-vlogflush=1s"})
+	if e != nil {
+		t.Fatalf("parse err=%v", e)
+	}
+	if c.Levels != "*=e,a=v1" || c.File != "/tmp/x" || c.Formatter != JSONFormatter ||
+		c.Rotate.MaxSize != 100 || c.Rotate.FlushInterval.Seconds() != 1 {
+		t.Errorf("got %+v", c)
+	}
+	if e := fs.Parse([]string{"-vlogformat=xml"}); e == nil {
+		t.Errorf("invalid format want err")
+	}
+}
+
+func TestInit(t *testing.T) {
+	testLogger(t)
+	prefix := filepath.Join(t.TempDir(), "app")
+
+	va := newVar("a", "")
+	if e := Init(Config{Levels: "a=x"}); e == nil {
+		t.Errorf("invalid levels want err")
+	}
+	l := NewWriterLogger(new(bytes.Buffer), TextFormatter)
+	SetLogger(l)
+	if e := Init(Config{Levels: "a=i"}); e != nil || logger() != l {
+		t.Errorf("init without file or format got err=%v, want Logger kept", e)
+	}
+	if e := Init(Config{Levels: "a=v1", File: prefix, Formatter: LogfmtFormatter}); e != nil {
+		t.Fatalf("init err=%v", e)
+	}
+	if *va != v1 {
+		t.Errorf("a got %v, want v1", va)
+	}
+	va.I("to file")
+	if e := Close(); e != nil {
+		t.Fatalf("close err=%v", e)
+	}
+	fns, _ := filepath.Glob(prefix + ".*.log")
+	if len(fns) != 1 {
+		t.Fatalf("got files %v, want 1", fns)
+	}
+	b, _ := ioutil.ReadFile(fns[0])
+	if !strings.Contains(string(b), ` level=info name=a caller=config_test.go:`) {
+		t.Errorf("got %q, want logfmt", b)
+	}
+}
+
+func TestParseTwice(t *testing.T) {
+	testLogger(t)
+	l := NewWriterLogger(new(bytes.Buffer), TextFormatter)
+	SetLogger(l)
+	Parse()
+	Parse()
+	if logger() != l {
+		t.Errorf("Parse without -vlogfile replaced the Logger")
+	}
+}
@@ -0,0 +1,193 @@
+package vlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Formatter formats a Record into bytes, ending with a newline.
+type Formatter interface {
+	Format(b *bytes.Buffer, r *Record)
+}
+
+// The built-in Formatters.
+var (
+	// TextFormatter formats a record as the time, the severity, the
+	// name of the Level variable ("*" for the default), the caller
+	// and the message followed by the fields as key=value, e.g.
+	//  2016/01/02 15:04:05.000000 E foo/bar foo.go:12: cache miss key=k1
+	TextFormatter Formatter = textFormatter{}
+
+	// LogfmtFormatter formats a record as logfmt, e.g.
+	//  time=2016-01-02T15:04:05.000000-07:00 level=err name=foo/bar caller=foo.go:12 msg="cache miss" key=k1
+	// A field keyed time, level, name, caller or msg is written
+	// with the key prefixed with "F_", e.g. F_msg.
+	LogfmtFormatter Formatter = logfmtFormatter{}
+
+	// JSONFormatter formats a record as a JSON object in a line, e.g.
+	//  {"time":"2016-01-02T15:04:05.000000-07:00","level":"err","name":"foo/bar","caller":"foo.go:12","msg":"cache miss","key":"k1"}
+	// The keys of the fields are prefixed as in LogfmtFormatter.
+	JSONFormatter Formatter = jsonFormatter{}
+)
+
+var formatters = map[string]Formatter{
+	"text":   TextFormatter,
+	"logfmt": LogfmtFormatter,
+	"json":   JSONFormatter,
+}
+
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func recordTime(r *Record) time.Time {
+	if r.Time.IsZero() {
+		return time.Now()
+	}
+	return r.Time
+}
+
+func caller(r *Record) string {
+	if r.File == "" {
+		return ""
+	}
+	return path.Base(r.File) + ":" + strconv.Itoa(r.Line)
+}
+
+// severity returns the tag of l in a text line, the upper case of
+// the level in -vlog.
+func (l Level) severity() string {
+	switch l {
+	case err:
+		return "E"
+	case warn:
+		return "W"
+	case info:
+		return "I"
+	case v1:
+		return "V1"
+	case v2:
+		return "V2"
+	default:
+		return l.String()
+	}
+}
+
+type textFormatter struct{}
+
+func (textFormatter) Format(b *bytes.Buffer, r *Record) {
+	var tb [32]byte
+	b.Write(recordTime(r).AppendFormat(tb[:0], "2006/01/02 15:04:05.000000"))
+	b.WriteByte(' ')
+	b.WriteString(r.Level.severity())
+	b.WriteByte(' ')
+	writeText(b, r)
+	if b.Len() == 0 || b.Bytes()[b.Len()-1] != '\n' {
+		b.WriteByte('\n')
+	}
+}
+
+// writeText writes the name, the caller, the message and the fields of
+// r in a text line.
+func writeText(b *bytes.Buffer, r *Record) {
+	if r.Name == "" {
+		b.WriteByte('*')
+	} else {
+		b.WriteString(r.Name)
+	}
+	b.WriteByte(' ')
+	if c := caller(r); c != "" {
+		b.WriteString(c)
+		b.WriteString(": ")
+	}
+	b.WriteString(r.Msg)
+	for _, f := range r.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		b.WriteString(quoteValue(valueString(f.Value)))
+	}
+}
+
+type logfmtFormatter struct{}
+
+func (logfmtFormatter) Format(b *bytes.Buffer, r *Record) {
+	var tb [40]byte
+	b.WriteString("time=")
+	b.Write(recordTime(r).AppendFormat(tb[:0], timeFormat))
+	b.WriteString(" level=")
+	b.WriteString(r.Level.String())
+	if r.Name != "" {
+		b.WriteString(" name=")
+		b.WriteString(quoteValue(r.Name))
+	}
+	if c := caller(r); c != "" {
+		b.WriteString(" caller=")
+		b.WriteString(quoteValue(c))
+	}
+	b.WriteString(" msg=")
+	b.WriteString(quoteValue(r.Msg))
+	for _, f := range r.Fields {
+		b.WriteByte(' ')
+		b.WriteString(quoteValue(fieldKey(f.Key)))
+		b.WriteByte('=')
+		b.WriteString(quoteValue(valueString(f.Value)))
+	}
+	b.WriteByte('\n')
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(b *bytes.Buffer, r *Record) {
+	var tb [40]byte
+	b.WriteString(`{"time":"`)
+	b.Write(recordTime(r).AppendFormat(tb[:0], timeFormat))
+	b.WriteString(`","level":"`)
+	b.WriteString(r.Level.String())
+	b.WriteByte('"')
+	if r.Name != "" {
+		b.WriteString(`,"name":`)
+		writeJSON(b, r.Name)
+	}
+	if c := caller(r); c != "" {
+		b.WriteString(`,"caller":`)
+		writeJSON(b, c)
+	}
+	b.WriteString(`,"msg":`)
+	writeJSON(b, r.Msg)
+	for _, f := range r.Fields {
+		b.WriteByte(',')
+		writeJSON(b, fieldKey(f.Key))
+		b.WriteByte(':')
+		v := f.Value
+		if _, ok := v.(error); ok {
+			v = valueString(v) // most errors marshal to {}
+		}
+		if !writeJSON(b, v) {
+			writeJSON(b, valueString(f.Value))
+		}
+	}
+	b.WriteString("}\n")
+}
+
+// fieldKey returns k as the key of a field in logfmt and JSON,
+// prefixed with "F_" if k is one of the keys set by the formatter.
+func fieldKey(k string) string {
+	switch k {
+	case "time", "level", "name", "caller", "msg":
+		return "F_" + k
+	}
+	return k
+}
+
+// writeJSON writes v marshaled as JSON to b.
+// It writes nothing and returns false if v cannot be marshaled.
+func writeJSON(b *bytes.Buffer, v interface{}) bool {
+	j, e := json.Marshal(v)
+	if e != nil {
+		return false
+	}
+	b.Write(j)
+	return true
+}
@@ -0,0 +1,109 @@
+package vlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nilStringer struct{ s string }
+
+func (ns *nilStringer) String() string { return ns.s }
+
+func TestFormatters(t *testing.T) {
+	r := &Record{
+		Time:  time.Date(2016, 1, 2, 15, 4, 5, 6000, time.FixedZone("", -7*3600)),
+		Level: err,
+		Name:  "foo/bar",
+		File:  "/src/foo/foo.go",
+		Line:  12,
+		Msg:   "cache miss",
+		Fields: []Field{
+			{"key", "k1"},
+			{"shard", 3},
+			{"err", errors.New("not found")},
+			{"empty", ""},
+			{"eq", "a=b"},
+			{"nilerr", (*os.PathError)(nil)},
+			{"nilstr", (*nilStringer)(nil)},
+		},
+	}
+	testcases := []struct {
+		f    Formatter
+		want string
+	}{
+		{
+			TextFormatter,
+			`2016/01/02 15:04:05.000006 E foo/bar foo.go:12: cache miss key=k1 shard=3 err="not found" empty="" eq="a=b" nilerr=<nil> nilstr=<nil>` + "\n",
+		},
+		{
+			LogfmtFormatter,
+			`time=2016-01-02T15:04:05.000006-07:00 level=err name=foo/bar caller=foo.go:12 msg="cache miss" key=k1 shard=3 err="not found" empty="" eq="a=b" nilerr=<nil> nilstr=<nil>` + "\n",
+		},
+		{
+			JSONFormatter,
+			`{"time":"2016-01-02T15:04:05.000006-07:00","level":"err","name":"foo/bar","caller":"foo.go:12","msg":"cache miss","key":"k1","shard":3,"err":"not found","empty":"","eq":"a=b","nilerr":"\u003cnil\u003e","nilstr":null}` + "\n",
+		},
+	}
+	for i, tc := range testcases {
+		var b bytes.Buffer
+		tc.f.Format(&b, r)
+		if got := b.String(); got != tc.want {
+			t.Errorf("%d: got %s, want %s", i, got, tc.want)
+		}
+	}
+}
+
+func TestFieldKey(t *testing.T) {
+	r := &Record{
+		Level:  info,
+		Msg:    "hello",
+		Fields: []Field{{"msg", "m"}, {"level", "l"}, {"time", "t"}, {"name", "n"}, {"caller", "c"}},
+	}
+	var b bytes.Buffer
+	LogfmtFormatter.Format(&b, r)
+	want := ` msg=hello F_msg=m F_level=l F_time=t F_name=n F_caller=c` + "\n"
+	if got := b.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("logfmt got %s, want suffix %s", got, want)
+	}
+
+	b.Reset()
+	JSONFormatter.Format(&b, r)
+	var m map[string]string
+	if e := json.Unmarshal(b.Bytes(), &m); e != nil {
+		t.Fatalf("unmarshal %s: %v", b.String(), e)
+	}
+	if m["msg"] != "hello" || m["level"] != "info" || m["F_msg"] != "m" || m["F_level"] != "l" ||
+		m["F_time"] != "t" || m["F_name"] != "n" || m["F_caller"] != "c" {
+		t.Errorf("json got %s", b.String())
+	}
+}
+
+func TestTextSeverity(t *testing.T) {
+	b := testLogger(t)
+
+	v := newVar("foo/bar", "")
+	setLevels("foo/bar=v2")
+	testcases := []struct {
+		fn   func(...interface{})
+		want string
+	}{
+		{v.E, " E foo/bar format_test.go:"},
+		{v.W, " W foo/bar format_test.go:"},
+		{v.I, " I foo/bar format_test.go:"},
+		{v.V1, " V1 foo/bar format_test.go:"},
+		{v.V2, " V2 foo/bar format_test.go:"},
+		{I, " I * format_test.go:"},
+	}
+	for i, tc := range testcases {
+		b.Reset()
+		tc.fn("msg")
+		if got := b.String(); !strings.Contains(got, tc.want) || !strings.HasSuffix(got, ": msg\n") {
+			t.Errorf("%d: got %q, want %q", i, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package vlog
+
+import (
+	"bytes"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// writeHeader writes the header record to the new file.
+// The header is not counted in the size of the file.
+func (rl *rotateLogger) writeHeader() {
+	r := &Record{
+		Time:  time.Now(),
+		Level: info,
+		Msg:   "log file header",
+		Fields: []Field{
+			{"host", hostname},
+			{"pid", os.Getpid()},
+			{"build", buildInfo},
+			{"cmdline", strings.Join(os.Args, " ")},
+			{"vlog", printLevelVars()},
+			{"prev", rl.prevFn},
+		},
+	}
+	var b bytes.Buffer // rl.buf may hold the record to log
+	rl.fmt.Format(&b, r)
+	rl.write(b.Bytes()) // error is caught by the next write
+}
+
+var hostname = func() string {
+	h, e := os.Hostname()
+	if e != nil {
+		return "unknown"
+	}
+	return h
+}()
+
+// buildInfo is the main module, version and vcs settings of the binary.
+var buildInfo = func() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	s := bi.Path
+	if bi.Main.Version != "" {
+		s += "@" + bi.Main.Version
+	}
+	s += " " + bi.GoVersion
+	for _, kv := range bi.Settings {
+		switch kv.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified":
+			s += " " + kv.Key + "=" + kv.Value
+		}
+	}
+	return s
+}()
@@ -0,0 +1,99 @@
+package vlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Handler returns an http.Handler to view and change the levels of
+// the Level variables.
+//
+// GET lists the Level variables, one "name level file" per line.
+// The default variable is listed with name "*". The list is in JSON
+// if the query has format=json or the request accepts application/json.
+//
+// POST or PUT sets the levels with SetLevels. The spec, in the same
+// format as the -vlog flag, is either the form value "vlog" or the
+// request body, e.g. curl -d 'foo=v2'. The new levels are listed in
+// the response.
+func Handler() http.Handler {
+	return http.HandlerFunc(serveLevels)
+}
+
+type levelVarJSON struct {
+	Name  string `json:"name"`
+	File  string `json:"file,omitempty"`
+	Level string `json:"level"`
+}
+
+func serveLevels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+	case "POST", "PUT":
+		spec, e := readSpec(w, r)
+		if e == nil {
+			e = SetLevels(spec)
+		}
+		if e != nil {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+		logger().Log(newRecord(0, info, Format("vlog setting changed by %s:%s", r.RemoteAddr, printLevelVars()), nil))
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lvs := copyLevelVars()
+	if r.FormValue("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		out := make([]levelVarJSON, len(lvs))
+		for i, lv := range lvs {
+			out[i] = levelVarJSON{Name: lv.Name, File: lv.File, Level: lv.Level.String()}
+		}
+		out[0].Name = "*"
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "* %v\n", lvs[0].Level)
+	for _, lv := range lvs[1:] {
+		fmt.Fprintf(w, "%s %v %s\n", lv.Name, lv.Level, lv.File)
+	}
+}
+
+// readSpec returns the spec in the query or the form value "vlog", or
+// in the request body. A form body without "vlog" is taken as a raw
+// spec, since curl -d 'foo=v2' sends it as a form.
+func readSpec(w http.ResponseWriter, r *http.Request) (string, error) {
+	if spec := r.URL.Query().Get("vlog"); spec != "" {
+		return spec, nil
+	}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "multipart/form-data" {
+		if spec := r.FormValue("vlog"); spec != "" {
+			return spec, nil
+		}
+		return "", fmt.Errorf("no vlog setting")
+	}
+	b, e := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+	if e != nil {
+		return "", e
+	}
+	spec := strings.TrimSpace(string(b))
+	if ct == "application/x-www-form-urlencoded" {
+		if q, e := url.ParseQuery(spec); e == nil && q.Get("vlog") != "" {
+			spec = q.Get("vlog")
+		}
+	}
+	if spec == "" {
+		return "", fmt.Errorf("no vlog setting")
+	}
+	return spec, nil
+}
@@ -0,0 +1,78 @@
+package vlog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	testLogger(t)
+	va := newVar("a", "a.go")
+	vb := newVar("b/c", "b/c/c.go")
+
+	h := Handler()
+	do := func(method, target, body, ctype string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if ctype != "" {
+			r.Header.Set("Content-Type", ctype)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", "/", "", "")
+	if want := "* info\na info a.go\nb/c info b/c/c.go\n"; w.Body.String() != want {
+		t.Errorf("GET got %q, want %q", w.Body.String(), want)
+	}
+
+	w = do("POST", "/", "a=v1,b/*=e", "text/plain")
+	if w.Code != http.StatusOK || *va != v1 || *vb != err {
+		t.Errorf("POST body got code=%d a=%v b/c=%v", w.Code, va, vb)
+	}
+
+	form := url.Values{"vlog": {"*=e,a=v2"}}.Encode()
+	w = do("PUT", "/?format=json", form, "application/x-www-form-urlencoded")
+	var got []levelVarJSON
+	if e := json.Unmarshal(w.Body.Bytes(), &got); e != nil {
+		t.Fatalf("PUT json err=%v body=%s", e, w.Body)
+	}
+	want := []levelVarJSON{
+		{Name: "*", Level: "err"},
+		{Name: "a", File: "a.go", Level: "v2"},
+		{Name: "b/c", File: "b/c/c.go", Level: "err"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("PUT got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("PUT %d got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// curl -d 'a=v1'
+	w = do("POST", "/", "a=v1", "application/x-www-form-urlencoded")
+	if w.Code != http.StatusOK || *va != v1 {
+		t.Errorf("POST curl -d got code=%d a=%v", w.Code, va)
+	}
+
+	w = do("POST", "/?vlog=a=v2", "", "")
+	if w.Code != http.StatusOK || *va != v2 {
+		t.Errorf("POST query got code=%d a=%v", w.Code, va)
+	}
+
+	w = do("POST", "/", "a=x", "text/plain")
+	if w.Code != http.StatusBadRequest || *va != v2 {
+		t.Errorf("POST invalid got code=%d a=%v", w.Code, va)
+	}
+
+	w = do("DELETE", "/", "", "")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE got code=%d", w.Code)
+	}
+}
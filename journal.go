@@ -0,0 +1,209 @@
+package vlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// journalSocket is the socket of the journald native protocol.
+var journalSocket = "/run/systemd/journal/socket"
+
+// NewJournalLogger returns a Logger that sends the records to
+// systemd-journald in its native protocol, with the fields MESSAGE,
+// PRIORITY, CODE_FILE, CODE_LINE, SYSLOG_IDENTIFIER, VLOG_NAME and the
+// fields of the record in upper case, e.g. key=v1 as KEY=v1. A field
+// named like the fields above is prefixed by "F_", e.g. F_PRIORITY.
+//
+// If the journal socket is absent, the records are written to stderr
+// as text lines prefixed by the priority, e.g. "<6>", which journald
+// understands on the stderr of a service. The socket is retried with
+// exponential backoff.
+func NewJournalLogger() Logger {
+	jl := &journalLogger{
+		ident: filepath.Base(os.Args[0]),
+		w:     os.Stderr,
+	}
+	jl.dial()
+	return jl
+}
+
+type journalLogger struct {
+	mu     sync.Mutex
+	ident  string
+	conn   net.Conn  // nil if the socket is unreachable
+	w      io.Writer // the fallback
+	buf    bytes.Buffer
+	closed bool
+
+	// When the socket is unreachable, it is retried at retryAt.
+	backoff time.Duration
+	retryAt time.Time
+}
+
+func (jl *journalLogger) Log(r *Record) {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	if jl.closed {
+		return
+	}
+	if jl.conn == nil && !time.Now().Before(jl.retryAt) {
+		jl.dial()
+	}
+	if jl.conn != nil {
+		jl.buf.Reset()
+		jl.format(&jl.buf, r)
+		e := jl.send()
+		if e == nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "vlog: journal err=%v, log to stderr\n", e)
+	}
+	jl.buf.Reset()
+	jl.buf.WriteByte('<')
+	jl.buf.WriteString(strconv.Itoa(syslogSeverity(r.Level)))
+	jl.buf.WriteByte('>')
+	writeText(&jl.buf, r)
+	if n := jl.buf.Len(); jl.buf.Bytes()[n-1] != '\n' {
+		jl.buf.WriteByte('\n')
+	}
+	jl.w.Write(jl.buf.Bytes())
+}
+
+// send sends the entry in jl.buf, in a file if it is too large for a
+// datagram. If the send fails, e.g. after journald is restarted, send
+// reconnects and resends once.
+func (jl *journalLogger) send() error {
+	_, e := jl.conn.Write(jl.buf.Bytes())
+	if e == nil {
+		return nil
+	}
+	if errors.Is(e, syscall.EMSGSIZE) {
+		return sendJournalFile(jl.conn, jl.buf.Bytes()) // the connection is fine
+	}
+	jl.conn.Close()
+	jl.conn = nil
+	if jl.dial(); jl.conn == nil {
+		return e
+	}
+	if _, e = jl.conn.Write(jl.buf.Bytes()); e != nil {
+		jl.conn.Close()
+		jl.conn = nil
+		jl.fail()
+	}
+	return e
+}
+
+// dial connects to the journal socket, or schedules a retry with
+// exponential backoff.
+func (jl *journalLogger) dial() {
+	c, e := net.Dial("unixgram", journalSocket)
+	if e != nil {
+		jl.fail()
+		return
+	}
+	jl.conn = c
+	jl.backoff = 0
+}
+
+func (jl *journalLogger) fail() {
+	jl.backoff *= 2
+	if jl.backoff < logRetryMin {
+		jl.backoff = logRetryMin
+	} else if jl.backoff > logRetryMax {
+		jl.backoff = logRetryMax
+	}
+	jl.retryAt = time.Now().Add(jl.backoff)
+}
+
+func (jl *journalLogger) format(b *bytes.Buffer, r *Record) {
+	writeJournalField(b, "MESSAGE", strings.TrimSuffix(r.Msg, "\n"))
+	writeJournalField(b, "PRIORITY", strconv.Itoa(syslogSeverity(r.Level)))
+	if r.File != "" {
+		writeJournalField(b, "CODE_FILE", r.File)
+		writeJournalField(b, "CODE_LINE", strconv.Itoa(r.Line))
+	}
+	writeJournalField(b, "SYSLOG_IDENTIFIER", jl.ident)
+	if r.Name != "" {
+		writeJournalField(b, "VLOG_NAME", r.Name)
+	}
+	for _, f := range r.Fields {
+		writeJournalField(b, journalKey(f.Key), valueString(f.Value))
+	}
+}
+
+// writeJournalField writes a field as KEY=value, or if value contains
+// a newline, as KEY, a newline, the 64-bit little endian length of
+// value and value.
+func writeJournalField(b *bytes.Buffer, key, value string) {
+	b.WriteString(key)
+	if strings.IndexByte(value, '\n') < 0 {
+		b.WriteByte('=')
+	} else {
+		var n [8]byte
+		binary.LittleEndian.PutUint64(n[:], uint64(len(value)))
+		b.WriteByte('\n')
+		b.Write(n[:])
+	}
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalKey returns k as a journal field name, which has only upper
+// case letters, digits and '_', does not begin with a digit or '_',
+// and is not one of the fields set by journalLogger.
+func journalKey(k string) string {
+	b := make([]byte, 0, len(k)+2)
+	if k == "" || k[0] == '_' || k[0] >= '0' && k[0] <= '9' {
+		b = append(b, 'F')
+	}
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		default:
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	if journalFields[string(b)] {
+		return "F_" + string(b)
+	}
+	return string(b)
+}
+
+// journalFields are the fields set by journalLogger.
+var journalFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"SYSLOG_IDENTIFIER": true,
+	"VLOG_NAME":         true,
+}
+
+func (jl *journalLogger) Flush() {}
+
+func (jl *journalLogger) Close() error {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	jl.closed = true
+	if jl.conn == nil {
+		return nil
+	}
+	e := jl.conn.Close()
+	jl.conn = nil
+	return e
+}
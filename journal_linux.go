@@ -0,0 +1,35 @@
+package vlog
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// sendJournalFile sends b, an entry too large for a datagram, to
+// journald in an unlinked file in /dev/shm, like libsystemd does when
+// memfd is not available.
+func sendJournalFile(c net.Conn, b []byte) error {
+	f, e := os.CreateTemp("/dev/shm", "vlog-journal.")
+	if e != nil {
+		return e
+	}
+	os.Remove(f.Name())
+	defer f.Close()
+	if _, e := f.Write(b); e != nil {
+		return e
+	}
+	// WriteMsgUnix refuses a connected unixgram socket
+	rc, e := c.(*net.UnixConn).SyscallConn()
+	if e != nil {
+		return e
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	if e2 := rc.Write(func(fd uintptr) bool {
+		e = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return e != syscall.EAGAIN
+	}); e2 != nil {
+		return e2
+	}
+	return e
+}
@@ -0,0 +1,50 @@
+package vlog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestJournalLoggerLargeEntry(t *testing.T) {
+	oldsock := journalSocket
+	defer func() { journalSocket = oldsock }()
+	journalSocket = filepath.Join(t.TempDir(), "socket")
+	l := listenSyslog(t, journalSocket)
+	defer l.Close()
+
+	jl := NewJournalLogger()
+	defer jl.Close()
+	jl.(*journalLogger).w = io.Discard
+	large := strings.Repeat("x", 1<<20)
+	jl.Log(&Record{Msg: "large", Fields: []Field{{"data", large}}})
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	l.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, oobn, _, _, e := l.ReadMsgUnix(nil, oob)
+	if e != nil {
+		t.Fatal(e)
+	}
+	msgs, e := syscall.ParseSocketControlMessage(oob[:oobn])
+	if e != nil || len(msgs) != 1 {
+		t.Fatalf("got control messages %v err=%v", msgs, e)
+	}
+	fds, e := syscall.ParseUnixRights(&msgs[0])
+	if e != nil || len(fds) != 1 {
+		t.Fatalf("got fds %v err=%v", fds, e)
+	}
+	f := os.NewFile(uintptr(fds[0]), "entry")
+	defer f.Close()
+	f.Seek(0, io.SeekStart)
+	b, e := io.ReadAll(f)
+	if e != nil || !strings.HasPrefix(string(b), "MESSAGE=large\n") || !strings.HasSuffix(string(b), "DATA="+large+"\n") {
+		t.Errorf("got %d bytes err=%v", len(b), e)
+	}
+	if jl.(*journalLogger).conn == nil {
+		t.Errorf("large entry dropped the connection")
+	}
+}
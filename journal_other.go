@@ -0,0 +1,13 @@
+//go:build !linux
+
+package vlog
+
+import (
+	"errors"
+	"net"
+)
+
+// sendJournalFile is not supported without journald.
+func sendJournalFile(c net.Conn, b []byte) error {
+	return errors.New("journal entry too large")
+}
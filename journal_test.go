@@ -0,0 +1,96 @@
+package vlog
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalLogger(t *testing.T) {
+	oldsock := journalSocket
+	defer func() { journalSocket = oldsock }()
+	journalSocket = filepath.Join(t.TempDir(), "socket")
+	l := listenSyslog(t, journalSocket)
+	defer l.Close()
+
+	jl := NewJournalLogger()
+	defer jl.Close()
+	jl.(*journalLogger).ident = "foo"
+	jl.Log(&Record{Level: warn, Name: "foo/bar", File: "/a/foo.go", Line: 12, Msg: "hello",
+		Fields: []Field{{"req-id", 7}, {"body", "a\nb"}}})
+	want := "MESSAGE=hello\nPRIORITY=4\nCODE_FILE=/a/foo.go\nCODE_LINE=12\n" +
+		"SYSLOG_IDENTIFIER=foo\nVLOG_NAME=foo/bar\nREQ_ID=7\n" +
+		"BODY\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"
+	if got := readSyslog(t, l); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestJournalLoggerStderr(t *testing.T) {
+	oldsock := journalSocket
+	defer func() { journalSocket = oldsock }()
+	journalSocket = filepath.Join(t.TempDir(), "socket")
+
+	jl := NewJournalLogger()
+	var b bytes.Buffer
+	jl.(*journalLogger).w = &b
+	jl.Log(&Record{Level: err, Msg: "hello", Fields: []Field{{"k", "v"}}})
+	jl.Log(&Record{Level: v2, Name: "foo", Msg: "world\n"})
+	if want := "<3>* hello k=v\n<7>foo world\n"; b.String() != want {
+		t.Errorf("got %q want %q", b.String(), want)
+	}
+}
+
+func TestJournalLoggerReconnect(t *testing.T) {
+	oldsock := journalSocket
+	defer func() { journalSocket = oldsock }()
+	journalSocket = filepath.Join(t.TempDir(), "socket")
+
+	jl := NewJournalLogger().(*journalLogger)
+	defer jl.Close()
+	var b bytes.Buffer
+	jl.w = &b
+	jl.Log(&Record{Msg: "to stderr"})
+	if jl.conn != nil || b.String() != "<6>* to stderr\n" {
+		t.Fatalf("got conn=%v stderr=%q, want stderr", jl.conn, b.String())
+	}
+
+	// journald starts
+	l := listenSyslog(t, journalSocket)
+	defer l.Close()
+	jl.Log(&Record{Msg: "before retry"})
+	jl.retryAt = time.Now()
+	jl.Log(&Record{Msg: "to journal"})
+	if got := readSyslog(t, l); !strings.HasPrefix(got, "MESSAGE=to journal\n") {
+		t.Errorf("after retry got %q", got)
+	}
+
+	// journald restarts
+	l.Close()
+	l = listenSyslog(t, journalSocket)
+	jl.Log(&Record{Msg: "after restart"})
+	if got := readSyslog(t, l); !strings.HasPrefix(got, "MESSAGE=after restart\n") {
+		t.Errorf("after restart got %q", got)
+	}
+	if want := "<6>* to stderr\n<6>* before retry\n"; b.String() != want {
+		t.Errorf("stderr got %q, want %q", b.String(), want)
+	}
+}
+
+func TestJournalKey(t *testing.T) {
+	for k, want := range map[string]string{
+		"key":       "KEY",
+		"req-id":    "REQ_ID",
+		"_x":        "F_X",
+		"1a":        "F1A",
+		"":          "F",
+		"priority":  "F_PRIORITY",
+		"vlog_name": "F_VLOG_NAME",
+	} {
+		if got := journalKey(k); got != want {
+			t.Errorf("journalKey(%q)=%q want %q", k, got, want)
+		}
+	}
+}
@@ -4,9 +4,9 @@ package vlog
 
 import "fmt"
 
-const _Level_name = "v2v1infoerr"
+const _Level_name = "v2v1infowarnerr"
 
-var _Level_index = [...]uint8{2, 4, 8, 11}
+var _Level_index = [...]uint8{2, 4, 8, 12, 15}
 
 func (i Level) String() string {
 	i -= -2
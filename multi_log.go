@@ -0,0 +1,117 @@
+package vlog
+
+import (
+	"fmt"
+	"os"
+)
+
+// Sink is a Logger with the minimum level of the records to write.
+// The zero MinLevel is LevelInfo; LevelV2 writes all the records.
+type Sink struct {
+	Logger   Logger
+	MinLevel Level
+
+	// Sync writes the records to Logger in the logging goroutine,
+	// instead of through the queue of the sink. A blocked Logger then
+	// blocks the other sinks.
+	Sync bool
+}
+
+// NewMultiLogger returns a Logger that writes a record to every sink
+// whose MinLevel is enabled by the record, e.g. errors to stderr and
+// all the records to a file,
+//
+//	vlog.SetLogger(vlog.NewMultiLogger(
+//	  vlog.Sink{Logger: vlog.NewWriterLogger(os.Stderr, vlog.TextFormatter), MinLevel: vlog.LevelErr},
+//	  vlog.Sink{Logger: vlog.NewRotateLogger(prefix, opts), MinLevel: vlog.LevelV2}))
+//
+// Each sink formats the records with its own Formatter. A panic in a
+// sink is reported to stderr and does not stop the other sinks.
+//
+// Each sink has its own queue, as if wrapped by NewAsyncLogger with
+// OverflowDropOldest, so that a slow or blocked sink drops its oldest
+// records instead of blocking the others. Flush and Close wait for
+// every sink. The values of the Fields should not be modified after
+// logging, as in NewAsyncLogger.
+func NewMultiLogger(sinks ...Sink) Logger {
+	ml := &multiLogger{sinks: make([]Sink, len(sinks))}
+	for i, s := range sinks {
+		s.Logger = sinkLogger{s.Logger}
+		if !s.Sync {
+			s.Logger = NewAsyncLogger(s.Logger, AsyncOptions{Overflow: OverflowDropOldest})
+		}
+		ml.sinks[i] = s
+	}
+	return ml
+}
+
+type multiLogger struct {
+	sinks []Sink
+}
+
+func (ml *multiLogger) Log(r *Record) {
+	for _, s := range ml.sinks {
+		if r.Level >= s.MinLevel {
+			s.Logger.Log(r)
+		}
+	}
+}
+
+func (ml *multiLogger) Flush() {
+	for _, s := range ml.sinks {
+		s.Logger.Flush()
+	}
+}
+
+// Reopen reopens the sinks that reopen their log files.
+func (ml *multiLogger) Reopen() error {
+	var first error
+	for _, s := range ml.sinks {
+		if e := reopen(s.Logger); e != nil && first == nil {
+			first = e
+		}
+	}
+	return first
+}
+
+func (ml *multiLogger) Close() error {
+	var first error
+	for _, s := range ml.sinks {
+		if e := s.Logger.Close(); e != nil && first == nil {
+			first = e
+		}
+	}
+	return first
+}
+
+// sinkLogger recovers a panic in the Logger of a sink.
+type sinkLogger struct {
+	l Logger
+}
+
+func (sl sinkLogger) Log(r *Record) {
+	callSink(sl.l, func(l Logger) error { l.Log(r); return nil })
+}
+
+func (sl sinkLogger) Flush() {
+	callSink(sl.l, func(l Logger) error { l.Flush(); return nil })
+}
+
+func (sl sinkLogger) Reopen() error {
+	return callSink(sl.l, reopen)
+}
+
+func (sl sinkLogger) Close() error {
+	return callSink(sl.l, Logger.Close)
+}
+
+// callSink calls fn with l, and recovers a panic in l as an error.
+func callSink(l Logger, fn func(Logger) error) (e error) {
+	defer func() {
+		if x := recover(); x != nil {
+			e = fmt.Errorf("vlog: sink %T panic: %v", l, x)
+			fmt.Fprintln(os.Stderr, e)
+		}
+	}()
+	return fn(l)
+}
@@ -0,0 +1,86 @@
+package vlog
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type panicLogger struct{}
+
+func (panicLogger) Log(r *Record) { panic("log") }
+func (panicLogger) Flush()        { panic("flush") }
+func (panicLogger) Close() error  { panic("close") }
+
+func TestMultiLogger(t *testing.T) {
+	var be, ba bytes.Buffer
+	ml := NewMultiLogger(
+		Sink{Logger: NewWriterLogger(&be, TextFormatter), MinLevel: LevelErr},
+		Sink{Logger: panicLogger{}},
+		Sink{Logger: NewWriterLogger(&ba, JSONFormatter), MinLevel: LevelV2},
+	)
+	for _, l := range []Level{err, warn, info, v1, v2} {
+		ml.Log(&Record{Level: l, Msg: "msg-" + l.String()})
+	}
+	ml.Flush()
+	if e := ml.Close(); e == nil {
+		t.Errorf("close want err from panic")
+	}
+
+	if got := strings.Count(be.String(), "\n"); got != 1 || !strings.Contains(be.String(), " E * msg-err\n") {
+		t.Errorf("err sink got %q", be.String())
+	}
+	if got := strings.Count(ba.String(), "\n"); got != 5 || !strings.Contains(ba.String(), `"msg":"msg-v2"`) {
+		t.Errorf("all sink got %q", ba.String())
+	}
+}
+
+func TestMultiLoggerReopen(t *testing.T) {
+	bl := &blockLogger{block: make(chan struct{})}
+	close(bl.block)
+	ml := NewMultiLogger(
+		Sink{Logger: NewWriterLogger(new(bytes.Buffer), TextFormatter)},
+		Sink{Logger: bl},
+	)
+	ml.Log(&Record{Msg: "m"})
+	if e := ml.(reopener).Reopen(); e != nil {
+		t.Fatalf("reopen err=%v", e)
+	}
+	if len(bl.reopened) != 1 || bl.reopened[0] != 1 {
+		t.Errorf("reopened got %v, want after 1 msg", bl.reopened)
+	}
+}
+
+func TestMultiLoggerBlockedSink(t *testing.T) {
+	blocked := &blockLogger{block: make(chan struct{})}
+	free := &blockLogger{block: make(chan struct{})}
+	close(free.block)
+	ml := NewMultiLogger(Sink{Logger: blocked}, Sink{Logger: free})
+
+	n := 2000 // more than the queue holds
+	for i := 0; i < n; i++ {
+		ml.Log(&Record{Msg: strconv.Itoa(i)})
+	}
+	// The free sink gets the newest records while the other is blocked.
+	last := strconv.Itoa(n - 1)
+	got := false
+	for i := 0; i < 100 && !got; i++ {
+		time.Sleep(10 * time.Millisecond)
+		free.mu.Lock()
+		for _, m := range free.msgs {
+			got = got || m == last
+		}
+		free.mu.Unlock()
+	}
+	if !got {
+		t.Errorf("free sink did not get msg %s", last)
+	}
+
+	close(blocked.block)
+	ml.Close()
+	if last := blocked.msgs[len(blocked.msgs)-1]; !strings.HasSuffix(last, " messages dropped") {
+		t.Errorf("blocked sink got last msg %q, want dropped", last)
+	}
+}
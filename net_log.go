@@ -0,0 +1,276 @@
+package vlog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NetOptions configures the Logger returned by NewNetLogger.
+type NetOptions struct {
+	// Formatter formats the records. Default is TextFormatter.
+	Formatter Formatter
+
+	// TLS is the TLS config to connect to a tcp address with.
+	// Default is nil for no TLS.
+	TLS *tls.Config
+
+	// Backlog is the number of records kept while disconnected. The
+	// oldest records are dropped when the backlog is full. Default
+	// is 1024.
+	Backlog int
+}
+
+// netTimeout is the timeout to connect and to write to a collector.
+var netTimeout = 10 * time.Second
+
+// NewNetLogger returns a Logger that sends the records, one per line,
+// to a collector at addr on the network "tcp" or "udp", e.g.,
+//
+//	vlog.NewNetLogger("tcp", "collector:5170", vlog.NetOptions{Formatter: vlog.JSONFormatter})
+//
+// A goroutine writes the records to the connection, so that Log does
+// not block on the network. It reconnects with exponential backoff
+// when the connection fails, and keeps the records in a bounded
+// backlog in the meantime. The dropped records are counted, and a
+// "N messages dropped" warning is sent after reconnecting. Over udp a
+// record is sent as a datagram.
+//
+// Flush waits until the backlog is written to the connection, or
+// returns early if the connection fails. Close writes the backlog if
+// the collector is reachable, and closes the connection.
+func NewNetLogger(network, addr string, opts NetOptions) Logger {
+	if opts.Formatter == nil {
+		opts.Formatter = TextFormatter
+	}
+	if opts.Backlog <= 0 {
+		opts.Backlog = 1024
+	}
+	nl := &netLogger{
+		network: network,
+		addr:    addr,
+		tls:     opts.TLS,
+		packet:  strings.HasPrefix(network, "udp") || network == "unixgram",
+		fmt:     opts.Formatter,
+		max:     opts.Backlog,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	nl.cond = sync.NewCond(&nl.mu)
+	go nl.writeloop()
+	return nl
+}
+
+type netLogger struct {
+	network string
+	addr    string
+	tls     *tls.Config
+	packet  bool // a record per write
+	fmt     Formatter
+
+	mu       sync.Mutex
+	cond     *sync.Cond // broadcast when a write finishes
+	buf      bytes.Buffer
+	backlog  [][]byte
+	max      int
+	writing  int // the records being written
+	dropped  int // since the last report
+	down     bool
+	closed   bool
+	failures int
+
+	wake   chan struct{} // the backlog is not empty
+	done   chan struct{} // closed by Close
+	exited chan struct{} // closed when writeloop exits
+
+	// owned by writeloop
+	conn    net.Conn
+	backoff time.Duration
+}
+
+func (nl *netLogger) Log(r *Record) {
+	nl.mu.Lock()
+	if nl.closed {
+		nl.mu.Unlock()
+		return
+	}
+	nl.buf.Reset()
+	nl.fmt.Format(&nl.buf, r)
+	nl.push(append([]byte(nil), nl.buf.Bytes()...))
+	nl.mu.Unlock()
+	select {
+	case nl.wake <- struct{}{}:
+	default:
+	}
+}
+
+// push appends lines to the backlog, and drops the oldest records if
+// it is full. nl.mu must be held.
+func (nl *netLogger) push(lines ...[]byte) {
+	nl.backlog = append(nl.backlog, lines...)
+	if n := len(nl.backlog) - nl.max; n > 0 {
+		nl.dropped += n
+		nl.backlog = append(nl.backlog[:0], nl.backlog[n:]...)
+	}
+}
+
+func (nl *netLogger) Flush() {
+	nl.mu.Lock()
+	for !nl.down && !nl.closed && len(nl.backlog)+nl.writing > 0 {
+		nl.cond.Wait()
+	}
+	nl.mu.Unlock()
+}
+
+func (nl *netLogger) Close() error {
+	nl.mu.Lock()
+	if nl.closed {
+		nl.mu.Unlock()
+		return nil
+	}
+	nl.closed = true
+	nl.cond.Broadcast()
+	nl.mu.Unlock()
+	close(nl.done)
+	<-nl.exited
+	return nil
+}
+
+func (nl *netLogger) writeloop() {
+	defer close(nl.exited)
+	var retry <-chan time.Time // non-nil while waiting to reconnect
+	for {
+		select {
+		case <-nl.wake:
+			if retry != nil {
+				continue
+			}
+		case <-retry:
+			retry = nil
+		case <-nl.done:
+			nl.writeBacklog()
+			if nl.conn != nil {
+				nl.conn.Close()
+			}
+			return
+		}
+		if nl.writeBacklog() {
+			nl.backoff = 0
+			continue
+		}
+		nl.backoff = nl.nextBackoff()
+		retry = time.After(nl.backoff)
+	}
+}
+
+// writeBacklog writes the backlog until it is empty, and returns false
+// if the connection fails.
+func (nl *netLogger) writeBacklog() bool {
+	for {
+		nl.mu.Lock()
+		lines := nl.backlog
+		nl.backlog = nil
+		dropped := nl.dropped
+		if dropped > 0 {
+			nl.buf.Reset()
+			nl.fmt.Format(&nl.buf, &Record{Time: time.Now(), Level: warn,
+				Msg: Format("%d messages dropped", dropped)})
+			lines = append([][]byte{append([]byte(nil), nl.buf.Bytes()...)}, lines...)
+			nl.dropped = 0
+		}
+		nl.writing = len(lines)
+		nl.mu.Unlock()
+		if len(lines) == 0 {
+			return true
+		}
+
+		n, e := nl.write(lines)
+		nl.mu.Lock()
+		nl.writing = 0
+		if e != nil {
+			if n == 0 && dropped > 0 {
+				n = 1 // the report is made again
+				nl.dropped += dropped
+			}
+			rest := nl.backlog
+			nl.backlog = nil
+			nl.push(lines[n:]...)
+			nl.push(rest...)
+			nl.down = true
+			nl.failures++
+			fmt.Fprintf(os.Stderr, "vlog: net %s %s err=%v failures=%d, retry in %v\n",
+				nl.network, nl.addr, e, nl.failures, nl.nextBackoff())
+		} else {
+			nl.down = false
+		}
+		nl.cond.Broadcast()
+		nl.mu.Unlock()
+		if e != nil {
+			return false
+		}
+	}
+}
+
+// nextBackoff returns the backoff after a failure.
+func (nl *netLogger) nextBackoff() time.Duration {
+	d := nl.backoff * 2
+	if d < logRetryMin {
+		return logRetryMin
+	} else if d > logRetryMax {
+		return logRetryMax
+	}
+	return d
+}
+
+// write writes lines to the connection, connecting if needed, and
+// returns the number of the lines written.
+func (nl *netLogger) write(lines [][]byte) (int, error) {
+	if nl.conn == nil {
+		if e := nl.dial(); e != nil {
+			return 0, e
+		}
+	}
+	nl.conn.SetWriteDeadline(time.Now().Add(netTimeout))
+	n, e := 0, error(nil)
+	if nl.packet {
+		for ; n < len(lines); n++ {
+			if _, e = nl.conn.Write(lines[n]); e != nil {
+				break
+			}
+		}
+	} else {
+		bufs := append(net.Buffers(nil), lines...)
+		var nb int64
+		nb, e = bufs.WriteTo(nl.conn)
+		for ; n < len(lines) && nb >= int64(len(lines[n])); n++ {
+			nb -= int64(len(lines[n]))
+		}
+	}
+	if e != nil {
+		nl.conn.Close() // a partly written line is resent
+		nl.conn = nil
+	}
+	return n, e
+}
+
+func (nl *netLogger) dial() error {
+	d := &net.Dialer{Timeout: netTimeout}
+	var c net.Conn
+	var e error
+	if nl.tls != nil {
+		c, e = tls.DialWithDialer(d, nl.network, nl.addr, nl.tls)
+	} else {
+		c, e = d.Dial(nl.network, nl.addr)
+	}
+	if e != nil {
+		return e
+	}
+	nl.conn = c
+	return nil
+}
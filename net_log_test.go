@@ -0,0 +1,131 @@
+package vlog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// readLines accepts a connection on l and reads n lines.
+func readLines(t *testing.T, l net.Listener, n int) []string {
+	t.Helper()
+	c, e := l.Accept()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var lines []string
+	sc := bufio.NewScanner(c)
+	for len(lines) < n && sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if len(lines) < n {
+		t.Fatalf("read %d lines, want %d err=%v", len(lines), n, sc.Err())
+	}
+	return lines
+}
+
+func TestNetLogger(t *testing.T) {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+
+	nl := NewNetLogger("tcp", l.Addr().String(), NetOptions{Formatter: LogfmtFormatter})
+	defer nl.Close()
+	nl.Log(&Record{Level: info, Msg: "hello", Fields: []Field{{"k", "v"}}})
+	nl.Log(&Record{Level: err, Msg: "world"})
+	nl.Flush()
+	if n := len(nl.(*netLogger).backlog); n != 0 {
+		t.Errorf("backlog=%d after flush", n)
+	}
+	lines := readLines(t, l, 2)
+	if want := "level=info msg=hello k=v"; lines[0][len(lines[0])-len(want):] != want {
+		t.Errorf("got %q want suffix %q", lines[0], want)
+	}
+}
+
+func TestNetLoggerReconnect(t *testing.T) {
+	oldmin := logRetryMin
+	defer func() { logRetryMin = oldmin }()
+	logRetryMin = 10 * time.Millisecond
+
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	addr := l.Addr().String()
+	l.Close() // the collector is down
+
+	nl := NewNetLogger("tcp", addr, NetOptions{Formatter: JSONFormatter, Backlog: 3})
+	defer nl.Close()
+	nl.Log(&Record{Msg: "0"})
+	nl.Flush() // returns as the connection fails
+	for i := 1; i < 5; i++ {
+		nl.Log(&Record{Msg: strconv.Itoa(i)})
+	}
+
+	l, e = net.Listen("tcp", addr)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+	lines := readLines(t, l, 4)
+	for i, want := range []string{"2 messages dropped", "2", "3", "4"} {
+		var r map[string]interface{}
+		if e := json.Unmarshal([]byte(lines[i]), &r); e != nil || r["msg"] != want {
+			t.Errorf("line %d got %q want msg %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestNetLoggerUDP(t *testing.T) {
+	pc, e := net.ListenPacket("udp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pc.Close()
+
+	nl := NewNetLogger("udp", pc.LocalAddr().String(), NetOptions{})
+	defer nl.Close()
+	nl.Log(&Record{Msg: "hello"})
+	nl.Log(&Record{Msg: "world"})
+	nl.Flush()
+	b := make([]byte, 4096)
+	for _, want := range []string{"hello", "world"} {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, e := pc.ReadFrom(b)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if got := string(b[:n]); got[len(got)-len(want)-1:] != want+"\n" {
+			t.Errorf("got %q want %q", got, want)
+		}
+	}
+}
+
+func TestNetLoggerTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	l, e := tls.Listen("tcp", "127.0.0.1:0", ts.TLS)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+
+	cfg := ts.Client().Transport.(*http.Transport).TLSClientConfig
+	nl := NewNetLogger("tcp", l.Addr().String(), NetOptions{TLS: cfg})
+	nl.Log(&Record{Msg: "hello"})
+	go nl.Close()
+	if lines := readLines(t, l, 1); lines[0][len(lines[0])-5:] != "hello" {
+		t.Errorf("got %q", lines[0])
+	}
+}
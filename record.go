@@ -0,0 +1,82 @@
+package vlog
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Record is a log message passed to a Logger.
+// A Logger must not modify a Record, which may be shared by Loggers.
+type Record struct {
+	Time   time.Time
+	Level  Level
+	Name   string // name of the Level variable
+	File   string // file of the caller, may be empty
+	Line   int    // line of the caller
+	Msg    string
+	Fields []Field
+}
+
+// Field is a key/value pair of a structured log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a Field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// fields converts alternating keys and values to Fields.
+// A Field in kvs is taken as is. A key without value gets
+// the value "!MISSING".
+func fields(kvs []interface{}) []Field {
+	if len(kvs) == 0 {
+		return nil
+	}
+	fs := make([]Field, 0, (len(kvs)+1)/2)
+	for i := 0; i < len(kvs); i++ {
+		if f, ok := kvs[i].(Field); ok {
+			fs = append(fs, f)
+			continue
+		}
+		k, ok := kvs[i].(string)
+		if !ok {
+			k = fmt.Sprint(kvs[i])
+		}
+		if i+1 == len(kvs) {
+			fs = append(fs, Field{Key: k, Value: "!MISSING"})
+			break
+		}
+		i++
+		fs = append(fs, Field{Key: k, Value: kvs[i]})
+	}
+	return fs
+}
+
+// valueString returns v as a string. Like fmt, it returns "<nil>" for
+// an error or Stringer that is a nil pointer.
+func valueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// quoteValue quotes s if s is empty or has space, quote, '=' or
+// non-printable characters.
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, c := range s {
+		if c == '"' || c == '=' || c == utf8.RuneError || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package vlog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	testcases := []struct {
+		in   []interface{}
+		want []Field
+	}{
+		{nil, nil},
+		{[]interface{}{"a", 1, "b", "x"}, []Field{{"a", 1}, {"b", "x"}}},
+		{[]interface{}{F("a", 1), "b", 2}, []Field{{"a", 1}, {"b", 2}}},
+		{[]interface{}{"a"}, []Field{{"a", "!MISSING"}}},
+		{[]interface{}{3, 4}, []Field{{"3", 4}}},
+	}
+	for i, tc := range testcases {
+		got := fields(tc.in)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestLogw(t *testing.T) {
+	b := testLogger(t)
+
+	v := Level(warn)
+	v.Iw("cache miss", "key", "k1")
+	if b.Len() != 0 {
+		t.Errorf("Iw at warn level got %q", b)
+	}
+	v.Ww("cache miss", "key", "k1", "shard", 2)
+	if got, want := b.String(), " W * record_test.go:"; !strings.Contains(got, want) {
+		t.Errorf("Ww got %q, want caller %q", got, want)
+	}
+	if got, want := b.String(), ": cache miss key=k1 shard=2\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("Ww got %q, want suffix %q", got, want)
+	}
+}
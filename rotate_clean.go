@@ -0,0 +1,184 @@
+package vlog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logFile is a log file named by rotateLogger.
+type logFile struct {
+	path  string
+	stamp string // YYYYMMDD-HHMMSS
+	id    int
+	size  int64
+	mtime time.Time
+}
+
+// logFilePattern returns the pattern of the names of the files
+// rotated with prefix.
+func logFilePattern(prefix string) *regexp.Regexp {
+	return regexp.MustCompile("^" + regexp.QuoteMeta(filepath.Base(prefix)) +
+		`\.(\d{8}-\d{6})\.(\d{2,})\.log(\.gz)?$`)
+}
+
+// rotatedFiles returns the files rotated with prefix, the newest first.
+func rotatedFiles(prefix string) ([]logFile, error) {
+	dir := filepath.Dir(prefix)
+	fis, e := ioutil.ReadDir(dir)
+	if e != nil {
+		return nil, e
+	}
+	re := logFilePattern(prefix)
+	var lfs []logFile
+	for _, fi := range fis {
+		m := re.FindStringSubmatch(fi.Name())
+		if m == nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		id, _ := strconv.Atoi(m[2])
+		lfs = append(lfs, logFile{
+			path:  filepath.Join(dir, fi.Name()),
+			stamp: m[1],
+			id:    id,
+			size:  fi.Size(),
+			mtime: fi.ModTime(),
+		})
+	}
+	sort.Slice(lfs, func(i, j int) bool {
+		if lfs[i].stamp != lfs[j].stamp {
+			return lfs[i].stamp > lfs[j].stamp
+		}
+		return lfs[i].id > lfs[j].id
+	})
+	return lfs, nil
+}
+
+// cleanloop compresses and deletes the rotated files in the background.
+func (rl *rotateLogger) cleanloop() {
+	defer rl.wg.Done()
+	for range rl.bgc {
+		rl.mu.Lock()
+		fns := rl.toCompress
+		rl.toCompress = nil
+		rl.mu.Unlock()
+		for _, fn := range fns {
+			if e := compressFile(fn); e != nil {
+				fmt.Fprintf(os.Stderr, "vlog: compress log file=%s err=%v\n", fn, e)
+			}
+		}
+		if rl.maxFiles > 0 || rl.maxAge > 0 || rl.maxTotalSize > 0 {
+			rl.cleanup()
+		}
+	}
+}
+
+// uncompressedFiles returns the rotated files left uncompressed, e.g.
+// by a crash or by a run without Compress, and removes their partial
+// compressed files. It is called before the first file is created, so
+// the current file is not returned. The files, and the partial files,
+// modified within idle may be written by another process with the same
+// prefix, and are skipped.
+func uncompressedFiles(prefix string, idle time.Duration) []string {
+	lfs, e := rotatedFiles(prefix)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "vlog: list log files prefix=%s err=%v\n", prefix, e)
+		return nil
+	}
+	var fns []string
+	for i := len(lfs) - 1; i >= 0; i-- { // oldest first
+		lf := lfs[i]
+		if !strings.HasSuffix(lf.path, ".log") || time.Since(lf.mtime) < idle {
+			continue
+		}
+		tmp := lf.path + ".gz.tmp"
+		if fi, e := os.Stat(tmp); e == nil {
+			if time.Since(fi.ModTime()) < idle {
+				continue
+			}
+			os.Remove(tmp) // ignore error
+		}
+		fns = append(fns, lf.path)
+	}
+	return fns
+}
+
+// compressFile gzips fn to fn.gz and removes fn.
+// fn.gz is written as fn.gz.tmp and renamed when complete.
+func compressFile(fn string) (e error) {
+	src, e := os.Open(fn)
+	if e != nil {
+		return e
+	}
+	defer src.Close()
+
+	tmp := fn + ".gz.tmp"
+	dst, e := os.Create(tmp)
+	if e != nil {
+		return e
+	}
+	defer func() {
+		if e != nil {
+			dst.Close()
+			os.Remove(tmp)
+		}
+	}()
+	zw := gzip.NewWriter(dst)
+	if _, e = io.Copy(zw, src); e != nil {
+		return e
+	}
+	if e = zw.Close(); e != nil {
+		return e
+	}
+	if e = dst.Sync(); e != nil {
+		return e
+	}
+	if e = dst.Close(); e != nil {
+		return e
+	}
+	if e = os.Rename(tmp, fn+".gz"); e != nil {
+		return e
+	}
+	return os.Remove(fn)
+}
+
+// cleanup deletes the oldest rotated files exceeding the limits.
+func (rl *rotateLogger) cleanup() {
+	rl.mu.Lock()
+	cur := rl.fn
+	rl.mu.Unlock()
+
+	lfs, e := rotatedFiles(rl.prefix)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "vlog: list log files prefix=%s err=%v\n", rl.prefix, e)
+		return
+	}
+	now := time.Now()
+	var total int64
+	nfiles := 0
+	for _, lf := range lfs {
+		total += lf.size
+		if lf.path == filepath.Clean(cur) {
+			nfiles++
+			continue // never delete the current file
+		}
+		if (rl.maxFiles > 0 && nfiles >= rl.maxFiles) ||
+			(rl.maxAge > 0 && now.Sub(lf.mtime) > rl.maxAge) ||
+			(rl.maxTotalSize > 0 && total > rl.maxTotalSize) {
+			if e := os.Remove(lf.path); e != nil {
+				fmt.Fprintf(os.Stderr, "vlog: remove log file=%s err=%v\n", lf.path, e)
+			}
+			total -= lf.size
+			continue
+		}
+		nfiles++
+	}
+}
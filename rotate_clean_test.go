@@ -0,0 +1,172 @@
+package vlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeLogFiles(t *testing.T, dir string, names ...string) {
+	for _, n := range names {
+		if e := ioutil.WriteFile(filepath.Join(dir, n), make([]byte, 100), 0644); e != nil {
+			t.Fatalf("write file=%s err=%v", n, e)
+		}
+	}
+}
+
+func listNames(t *testing.T, prefix string) []string {
+	lfs, e := rotatedFiles(prefix)
+	if e != nil {
+		t.Fatalf("list log files err=%v", e)
+	}
+	var names []string
+	for _, lf := range lfs {
+		names = append(names, filepath.Base(lf.path))
+	}
+	return names
+}
+
+func TestRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	writeLogFiles(t, dir,
+		"app.20160102-150405.00.log",
+		"app.20160102-150405.99.log",
+		"app.20160102-150405.100.log",
+		"app.20160101-000000.05.log",
+		"app.log",
+		"app.20160102-150405.00.log.bak",
+		"other.20160102-150405.00.log",
+		"app.x.20160102-150405.00.log",
+	)
+	got := listNames(t, filepath.Join(dir, "app"))
+	want := []string{
+		"app.20160102-150405.100.log",
+		"app.20160102-150405.99.log",
+		"app.20160102-150405.00.log",
+		"app.20160101-000000.05.log",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRotateLoggerCleanup(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+
+	old := []string{
+		"app.20160101-000000.00.log",
+		"app.20160101-000001.01.log",
+		"app.20160101-000002.02.log",
+		"app.20160101-000003.03.log",
+	}
+	writeLogFiles(t, dir, old...)
+	rl := &rotateLogger{prefix: prefix, maxFiles: 3}
+	rl.cleanup()
+	if got, want := listNames(t, prefix), []string{old[3], old[2], old[1]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("maxFiles got %v, want %v", got, want)
+	}
+
+	rl = &rotateLogger{prefix: prefix, maxTotalSize: 250}
+	rl.cleanup()
+	if got, want := listNames(t, prefix), []string{old[3], old[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("maxTotalSize got %v, want %v", got, want)
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(dir, old[2]), past, past)
+	rl = &rotateLogger{prefix: prefix, maxAge: time.Hour}
+	rl.cleanup()
+	if got, want := listNames(t, prefix), []string{old[3]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("maxAge got %v, want %v", got, want)
+	}
+
+	// the current file is kept
+	rl = &rotateLogger{prefix: prefix, fn: filepath.Join(dir, old[3]), maxTotalSize: 50}
+	rl.cleanup()
+	if got, want := listNames(t, prefix), []string{old[3]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("current got %v, want %v", got, want)
+	}
+}
+
+func TestRotateLoggerMaxFiles(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "rotate_clean_test")
+	pattern := prefix + "*.log"
+
+	rl := newRotateLogger(prefix, RotateOptions{MaxSize: 10, MaxFiles: 2})
+	defer rl.Close()
+	for i := 0; i < 5; i++ {
+		rl.Log(&Record{Msg: "0123456789"})
+	}
+	for i := 0; i < 100; i++ {
+		if fns, _ := filepath.Glob(pattern); len(fns) == 2 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	listLogFiles(t, 2, pattern)
+}
+
+func TestRotateLoggerCompress(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+
+	// left by a crashed run
+	crashed := []string{"app.20160101-000000.00.log", "app.20160101-000000.00.log.gz.tmp",
+		"app.20151231-000000.00.log"}
+	writeLogFiles(t, dir, crashed...)
+	old := time.Now().Add(-time.Hour)
+	for _, n := range crashed {
+		os.Chtimes(filepath.Join(dir, n), old, old)
+	}
+	// written by another process
+	writeLogFiles(t, dir, "app.20160101-000001.00.log",
+		"app.20160101-000002.00.log", "app.20160101-000002.00.log.gz.tmp")
+
+	rl := newRotateLogger(prefix, RotateOptions{MaxSize: 10, Compress: true})
+	defer rl.Close()
+	for i := 0; i < 3; i++ {
+		rl.Log(&Record{Msg: "0123456789"})
+	}
+	want := []string{".log", ".gz", ".gz", ".log", ".log", ".gz", ".gz"}
+	var names []string
+	for i := 0; i < 100; i++ {
+		names = listNames(t, prefix)
+		if len(names) == len(want) && filepath.Ext(names[1]) == ".gz" && filepath.Ext(names[5]) == ".gz" &&
+			filepath.Ext(names[6]) == ".gz" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(names) != len(want) {
+		t.Fatalf("got %v, want exts %v", names, want)
+	}
+	for i, n := range names {
+		if filepath.Ext(n) != want[i] {
+			t.Fatalf("got %v, want exts %v", names, want)
+		}
+	}
+	if tmps, _ := filepath.Glob(prefix + "*.tmp"); len(tmps) != 1 {
+		t.Errorf("got tmp files %v, want the one of the other process", tmps)
+	}
+
+	f, e := os.Open(filepath.Join(dir, names[1]))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer f.Close()
+	zr, e := gzip.NewReader(f)
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, e := ioutil.ReadAll(zr)
+	if e != nil || !bytes.HasSuffix(b, []byte(" 0123456789\n")) {
+		t.Errorf("gunzip got %q err=%v", b, e)
+	}
+}
@@ -1,83 +1,454 @@
 package vlog
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
-	"io"
-	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 )
 
+// RotateOptions configures the Logger returned by NewRotateLogger.
+type RotateOptions struct {
+	// Formatter formats the records. Default is TextFormatter.
+	Formatter Formatter
+
+	// MaxSize is the size limit of a file in bytes. Default is 1GiB.
+	MaxSize int
+
+	// FlushInterval is the interval to flush the buffered records to
+	// the file. Default is 29s.
+	FlushInterval time.Duration
+
+	// Interval, if positive, rotates the files at the multiples of
+	// Interval in the local wall clock, e.g. time.Hour rotates at the
+	// top of every hour, and 24*time.Hour rotates at midnight.
+	// It is combined with MaxSize.
+	Interval time.Duration
+
+	// MaxFiles, MaxAge and MaxTotalSize, if positive, limit the rotated
+	// files to keep. The oldest files exceeding any of the limits are
+	// deleted in the background after each rotation. MaxTotalSize is in
+	// bytes and includes the current file. Only the files named by vlog
+	// with the same prefix are deleted.
+	MaxFiles     int
+	MaxAge       time.Duration
+	MaxTotalSize int64
+
+	// Compress gzips a rotated file to "<name>.log.gz" in the background.
+	// The rotated files left uncompressed, e.g. by a crash, are
+	// compressed when the logger starts, unless they were modified
+	// within FlushInterval and may be written by another process.
+	Compress bool
+
+	// Symlink, if not empty, is the path of a symlink that is updated
+	// atomically to point to the current file after each rotation,
+	// e.g. "<prefix>.log", for tail -F.
+	Symlink string
+
+	// Reopen writes to the fixed file "<prefix>.log" for an external
+	// tool like logrotate, instead of rotating the files. The file is
+	// closed and reopened on SIGHUP or a call to Reopen. The other
+	// rotation options are ignored.
+	Reopen bool
+
+	// OnError, if not nil, is called in a new goroutine when the log
+	// file cannot be created or written. failures is the number of
+	// consecutive failures. Meanwhile the records, and the buffered
+	// records not written to the file, are written to stderr unbuffered,
+	// and the file is retried with exponential backoff.
+	OnError func(e error, failures int)
+
+	// PerSeverity writes a set of files per severity, like glog, named
+	// "<prefix>.<SEV>.YYYYMMDD-HHMMSS.NN.log", where SEV is ERROR,
+	// WARNING, INFO or V. A record is written to the files of its
+	// severity and every more verbose one, e.g. an error is written
+	// to all the sets and a v1 message only to V. Each set is rotated
+	// with the options above, and its symlink is "<Symlink>.<SEV>".
+	PerSeverity bool
+}
+
+// NewRotateLogger returns a Logger that writes to files named
+// "<prefix>.YYYYMMDD-HHMMSS.NN.log". A new file is created when the
+// size of the current file exceeds MaxSize, or the Interval is passed.
+//
+// Each file starts with a header record of the creation time, host,
+// pid, build info, command line, vlog setting and the previous file.
+func NewRotateLogger(prefix string, opts RotateOptions) Logger {
+	if opts.PerSeverity {
+		return newSeverityLogger(prefix, opts)
+	}
+	return newRotateLogger(prefix, opts)
+}
+
 type rotateLogger struct {
-	mu     sync.Mutex
-	lg     *log.Logger
-	wr     *bufio.Writer
-	f      io.WriteCloser
-	nbytes int
-	prefix string
-	nextID int
+	mu       sync.Mutex
+	closed   bool
+	done     chan struct{} // closed by Close
+	wg       sync.WaitGroup
+	fmt      Formatter
+	buf      bytes.Buffer
+	wbuf     bytes.Buffer // not yet written to f
+	f        *os.File
+	nbytes   int
+	limit    int
+	interval time.Duration
+	rotateAt time.Time // when interval is passed
+	prefix   string
+	fn       string // current file
+	prevFn   string // previous file, for the header
+	nextID   int
+
+	maxFiles     int
+	maxAge       time.Duration
+	maxTotalSize int64
+	compress     bool
+	toCompress   []string      // rotated files to compress
+	bgc          chan struct{} // signals the background cleanloop
+	symlink      string
+	reopen       bool
+
+	// When the log file fails, f is nil and the records are written
+	// to stderr unbuffered until retryAt.
+	onError  func(error, int)
+	failures int
+	backoff  time.Duration
+	retryAt  time.Time
 }
 
-func newRotateLogger(prefix string) *rotateLogger {
+func newRotateLogger(prefix string, opts RotateOptions) *rotateLogger {
+	if opts.Formatter == nil {
+		opts.Formatter = TextFormatter
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = logLimit
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = logFlushInterval
+	}
+	if opts.Reopen {
+		rl := &rotateLogger{
+			done:    make(chan struct{}),
+			prefix:  prefix,
+			fn:      prefix + ".log",
+			fmt:     opts.Formatter,
+			reopen:  true,
+			onError: opts.OnError,
+		}
+		if e := rl.reopenFile(); e != nil {
+			rl.fail(e)
+		}
+		// Notify before returning, so that a SIGHUP sent right after
+		// the logger is created is not missed.
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		go rl.reopenloop(c)
+		go rl.flushloop(opts.FlushInterval)
+		return rl
+	}
 	rl := &rotateLogger{
-		prefix: prefix,
+		done:     make(chan struct{}),
+		prefix:   prefix,
+		fmt:      opts.Formatter,
+		limit:    opts.MaxSize,
+		interval: opts.Interval,
+
+		maxFiles:     opts.MaxFiles,
+		maxAge:       opts.MaxAge,
+		maxTotalSize: opts.MaxTotalSize,
+		compress:     opts.Compress,
+		symlink:      opts.Symlink,
+		onError:      opts.OnError,
+	}
+	if rl.compress {
+		rl.toCompress = uncompressedFiles(prefix, opts.FlushInterval)
+	}
+	if rl.compress || rl.maxFiles > 0 || rl.maxAge > 0 || rl.maxTotalSize > 0 {
+		rl.bgc = make(chan struct{}, 1)
+		rl.wg.Add(1)
+		go rl.cleanloop()
 	}
 	rl.rotate()
-	go rl.flushloop()
+	go rl.flushloop(opts.FlushInterval)
 	return rl
 }
 
 var (
-	logPrefixSize    = 50
 	logLimit         = 1 << 30
 	logFlushInterval = 29 * time.Second
+	logRetryMin      = time.Second
+	logRetryMax      = time.Minute
+	logBufferSize    = 4096
 )
 
-func (rl *rotateLogger) Log(s string) {
+func (rl *rotateLogger) Log(r *Record) {
 	rl.mu.Lock()
-	for {
-		checklimit := rl.nbytes > 0
-		rl.nbytes += logPrefixSize + len(s)
-		if checklimit && rl.nbytes > logLimit {
-			rl.rotate()
-			continue // retry
+	if rl.closed {
+		rl.mu.Unlock()
+		return
+	}
+	rl.buf.Reset()
+	rl.fmt.Format(&rl.buf, r)
+	if rl.f == nil {
+		if !time.Now().Before(rl.retryAt) {
+			rl.retry()
 		}
-		rl.lg.Output(3, s)
-		break
+	} else if rl.reopen {
+		// rotated by an external tool
+	} else if rl.interval > 0 && !time.Now().Before(rl.rotateAt) {
+		rl.rotate()
+	} else if rl.nbytes > 0 && rl.nbytes+rl.buf.Len() > rl.limit {
+		rl.rotate()
+	}
+	rl.nbytes += rl.buf.Len()
+	if e := rl.write(rl.buf.Bytes()); e != nil {
+		rl.fail(e)
 	}
 	rl.mu.Unlock()
 }
 
 func (rl *rotateLogger) Flush() {
 	rl.mu.Lock()
-	rl.wr.Flush() // ignore error
+	if rl.closed {
+		rl.mu.Unlock()
+		return
+	}
+	if e := rl.flushBuf(); e != nil {
+		rl.fail(e)
+	}
 	rl.mu.Unlock()
 }
 
-func (rl *rotateLogger) rotate() {
+// Close flushes, syncs and closes the current file, and stops the
+// background goroutines after the pending compression and cleanup.
+// The records logged after Close are dropped.
+func (rl *rotateLogger) Close() error {
+	rl.mu.Lock()
+	if rl.closed {
+		rl.mu.Unlock()
+		return nil
+	}
+	rl.closed = true
+	close(rl.done)
+	e := rl.flushBuf()
+	if e != nil {
+		os.Stderr.Write(rl.wbuf.Bytes())
+	}
+	if rl.f != nil {
+		if e2 := rl.f.Sync(); e == nil {
+			e = e2
+		}
+		if e2 := rl.f.Close(); e == nil {
+			e = e2
+		}
+	}
+	rl.f = nil
+	if rl.bgc != nil {
+		close(rl.bgc)
+	}
+	rl.mu.Unlock()
+	rl.wg.Wait()
+	return e
+}
+
+// write writes b to the file, buffered, or to stderr if the file
+// failed.
+func (rl *rotateLogger) write(b []byte) error {
+	if rl.f == nil {
+		os.Stderr.Write(b)
+		return nil
+	}
+	rl.wbuf.Write(b)
+	if rl.wbuf.Len() < logBufferSize {
+		return nil
+	}
+	return rl.flushBuf()
+}
+
+// flushBuf writes the buffered bytes to the file, and keeps the bytes
+// not written.
+func (rl *rotateLogger) flushBuf() error {
+	if rl.f == nil || rl.wbuf.Len() == 0 {
+		return nil
+	}
+	n, e := rl.f.Write(rl.wbuf.Bytes())
+	rl.wbuf.Next(n)
+	return e
+}
+
+// closeFile flushes and closes the current file. The bytes not written
+// are written to stderr.
+func (rl *rotateLogger) closeFile() {
+	if e := rl.flushBuf(); e != nil {
+		os.Stderr.Write(rl.wbuf.Bytes())
+	}
+	rl.wbuf.Reset()
 	if rl.f != nil {
-		rl.wr.Flush()
 		rl.f.Close()
+		rl.prevFn = rl.fn
+		if rl.compress {
+			rl.toCompress = append(rl.toCompress, rl.fn)
+		}
+	}
+	rl.f = nil
+}
+
+// setFile sets f as the current file.
+func (rl *rotateLogger) setFile(f *os.File, fn string) {
+	rl.f = f
+	rl.fn = fn
+	rl.nbytes = 0
+	if rl.failures > 0 {
+		fmt.Fprintf(os.Stderr, "vlog: log to file=%s after failures=%d\n", fn, rl.failures)
+		rl.failures = 0
+		rl.backoff = 0
+	}
+}
+
+// fail switches to stderr when the log file cannot be created or
+// written, and schedules a retry with exponential backoff.
+func (rl *rotateLogger) fail(e error) {
+	if rl.f != nil {
+		os.Stderr.Write(rl.wbuf.Bytes()) // the lines not written
+		rl.wbuf.Reset()
+		rl.f.Close()
+		rl.f = nil
+	}
+	rl.failures++
+	rl.backoff *= 2
+	if rl.backoff < logRetryMin {
+		rl.backoff = logRetryMin
+	} else if rl.backoff > logRetryMax {
+		rl.backoff = logRetryMax
+	}
+	rl.retryAt = time.Now().Add(rl.backoff)
+	fmt.Fprintf(os.Stderr, "vlog: log file err=%v failures=%d, log to stderr and retry in %v\n",
+		e, rl.failures, rl.backoff)
+	if rl.onError != nil {
+		go rl.onError(e, rl.failures)
 	}
+}
+
+// retry creates or opens the log file after a failure.
+func (rl *rotateLogger) retry() {
+	if !rl.reopen {
+		rl.rotate()
+		return
+	}
+	if e := rl.reopenFile(); e != nil {
+		rl.fail(e)
+	}
+}
+
+func (rl *rotateLogger) rotate() {
+	rl.closeFile()
 	t := time.Now()
 	fn := fmt.Sprintf("%s.%04d%02d%02d-%02d%02d%02d.%02d.log",
 		rl.prefix, t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
 		rl.nextID)
-	f, err := os.Create(fn)
-	if err != nil {
-		panic(fmt.Sprintf("create log file=%s err=%v", fn, err))
+	f, e := os.Create(fn)
+	if e != nil {
+		rl.fail(e)
+		return
 	}
-	rl.f = f
-	rl.wr = bufio.NewWriter(f)
-	rl.lg = log.New(rl.wr, "", logPrefix)
-	rl.nbytes = 0
+	rl.setFile(f, fn)
+	rl.writeHeader()
 	rl.nextID++
+	if rl.symlink != "" {
+		if e := updateSymlink(rl.symlink, fn); e != nil {
+			fmt.Fprintf(os.Stderr, "vlog: update symlink=%s err=%v\n", rl.symlink, e)
+		}
+	}
+	if rl.interval > 0 {
+		rl.rotateAt = nextBoundary(t, rl.interval)
+	}
+	if rl.bgc != nil {
+		select {
+		case rl.bgc <- struct{}{}:
+		default: // a cleanup is pending
+		}
+	}
+}
+
+// Reopen closes and reopens the log file in the Reopen mode,
+// after the buffered lines are written to the old file.
+// If the file cannot be opened, the old file is kept.
+// Reopen is noop in the rotation mode.
+func (rl *rotateLogger) Reopen() error {
+	if !rl.reopen {
+		return nil
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.closed {
+		return nil
+	}
+	return rl.reopenFile()
+}
+
+func (rl *rotateLogger) reopenFile() error {
+	f, e := os.OpenFile(rl.fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if e != nil {
+		return e
+	}
+	rl.closeFile()
+	rl.setFile(f, rl.fn)
+	if fi, e := f.Stat(); e == nil && fi.Size() == 0 {
+		rl.writeHeader()
+	}
+	return nil
+}
+
+// reopenloop reopens the file on each signal from c, until the logger
+// is closed.
+func (rl *rotateLogger) reopenloop(c chan os.Signal) {
+	defer signal.Stop(c)
+	for {
+		select {
+		case <-c:
+			if e := rl.Reopen(); e != nil {
+				fmt.Fprintf(os.Stderr, "vlog: reopen log file=%s err=%v\n", rl.fn, e)
+			}
+		case <-rl.done:
+			return
+		}
+	}
+}
+
+// updateSymlink points link to target atomically, by renaming a new
+// symlink to link. target is relative if it is in the dir of link.
+func updateSymlink(link, target string) error {
+	if filepath.Dir(link) == filepath.Dir(target) {
+		target = filepath.Base(target)
+	}
+	tmp := link + ".tmp"
+	os.Remove(tmp) // ignore error
+	if e := os.Symlink(target, tmp); e != nil {
+		return e
+	}
+	return os.Rename(tmp, link)
+}
+
+// nextBoundary returns the first multiple of d after t in the local
+// wall clock of t.
+func nextBoundary(t time.Time, d time.Duration) time.Time {
+	_, off := t.Zone()
+	zone := time.Duration(off) * time.Second
+	return t.Add(zone).Truncate(d).Add(d).Add(-zone)
 }
 
-func (rl *rotateLogger) flushloop() {
-	for _ = range time.Tick(logFlushInterval) {
-		rl.Flush()
+func (rl *rotateLogger) flushloop(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			rl.Flush()
+		case <-rl.done:
+			return
+		}
 	}
 }
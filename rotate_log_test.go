@@ -1,10 +1,15 @@
 package vlog
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func cleanUpTmpLogs(t *testing.T, pattern string) {
@@ -38,15 +43,15 @@ func TestRotateLogger(t *testing.T) {
 	}(logLimit)
 	logLimit = 180
 
-	lg = newRotateLogger(prefix)
+	SetLogger(newRotateLogger(prefix, RotateOptions{}))
 	data1 := []string{"a", "bc"}
 	for _, d := range data1 {
-		lg.Log(d)
+		logger().Log(&Record{Msg: d})
 	}
 	listLogFiles(t, 1, pattern)
 	data2 := []string{string(make([]byte, 128)), "def"}
 	for _, d := range data2 {
-		lg.Log(d)
+		logger().Log(&Record{Msg: d})
 	}
 	listLogFiles(t, 3, pattern)
 }
@@ -56,14 +61,14 @@ func TestRotateLoggerN(t *testing.T) {
 	pattern := prefix + "*.log"
 	cleanUpTmpLogs(t, pattern)
 
-	rl := newRotateLogger(prefix)
-	lg = rl
+	rl := newRotateLogger(prefix, RotateOptions{})
+	SetLogger(rl)
 	ch := make(chan struct{}, 100)
 	for i := 0; i < 100; i++ {
 		i := i
 		go func() {
 			for j := 0; j < 50; j++ {
-				lg.Log(fmt.Sprintf("goroutine %d log %d", i, j))
+				logger().Log(&Record{Msg: fmt.Sprintf("goroutine %d log %d", i, j)})
 			}
 			ch <- struct{}{}
 		}()
@@ -76,3 +81,253 @@ func TestRotateLoggerN(t *testing.T) {
 	}
 	listLogFiles(t, 1, pattern)
 }
+
+func TestRotateLoggerInterval(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "rotate_log_test")
+	pattern := prefix + "*.log"
+
+	rl := newRotateLogger(prefix, RotateOptions{Interval: time.Hour})
+	defer rl.Close()
+	if !rl.rotateAt.After(time.Now()) || rl.rotateAt.Minute() != 0 || rl.rotateAt.Second() != 0 {
+		t.Errorf("rotateAt got %v, want next hour", rl.rotateAt)
+	}
+	rl.Log(&Record{Msg: "a"})
+	listLogFiles(t, 1, pattern)
+	rl.rotateAt = time.Now()
+	rl.Log(&Record{Msg: "b"})
+	listLogFiles(t, 2, pattern)
+}
+
+func TestNextBoundary(t *testing.T) {
+	loc := time.FixedZone("", -7*3600)
+	testcases := []struct {
+		t    time.Time
+		d    time.Duration
+		want time.Time
+	}{
+		{
+			time.Date(2016, 1, 2, 15, 4, 5, 0, loc),
+			time.Hour,
+			time.Date(2016, 1, 2, 16, 0, 0, 0, loc),
+		},
+		{
+			time.Date(2016, 1, 2, 15, 4, 5, 0, loc),
+			24 * time.Hour,
+			time.Date(2016, 1, 3, 0, 0, 0, 0, loc),
+		},
+		{
+			time.Date(2016, 1, 2, 15, 0, 0, 0, loc),
+			15 * time.Minute,
+			time.Date(2016, 1, 2, 15, 15, 0, 0, loc),
+		},
+	}
+	for i, tc := range testcases {
+		if got := nextBoundary(tc.t, tc.d); !got.Equal(tc.want) {
+			t.Errorf("%d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestRotateLoggerSymlink(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+	link := prefix + ".log"
+
+	rl := newRotateLogger(prefix, RotateOptions{Symlink: link})
+	defer rl.Close()
+	for i := 0; i < 2; i++ {
+		rl.Log(&Record{Msg: "a"})
+		rl.rotate()
+		target, e := os.Readlink(link)
+		if e != nil {
+			t.Fatalf("readlink err=%v", e)
+		}
+		if target != filepath.Base(rl.fn) {
+			t.Errorf("%d: link got %s, want %s", i, target, filepath.Base(rl.fn))
+		}
+	}
+	rl.Log(&Record{Msg: "b"})
+	rl.Flush()
+	b, e := ioutil.ReadFile(link)
+	if e != nil || !strings.HasSuffix(string(b), " b\n") {
+		t.Errorf("read link got %q err=%v", b, e)
+	}
+}
+
+func TestRotateLoggerReopen(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+	fn := prefix + ".log"
+
+	rl := newRotateLogger(prefix, RotateOptions{Reopen: true, MaxSize: 10})
+	defer rl.Close()
+	rl.Log(&Record{Msg: "a"})
+	rl.Log(&Record{Msg: "0123456789"})
+	if e := os.Rename(fn, fn+".1"); e != nil {
+		t.Fatal(e)
+	}
+	rl.Log(&Record{Msg: "b"}) // still to the renamed file
+	if e := rl.Reopen(); e != nil {
+		t.Fatalf("reopen err=%v", e)
+	}
+	rl.Log(&Record{Msg: "c"})
+	rl.Flush()
+
+	b, _ := ioutil.ReadFile(fn + ".1")
+	if n := strings.Count(string(b), "\n"); n != 4 || !strings.HasSuffix(string(b), " b\n") {
+		t.Errorf("old file got %q, want header and 3 lines", b)
+	}
+	b, _ = ioutil.ReadFile(fn)
+	if n := strings.Count(string(b), "\n"); n != 2 || !strings.HasSuffix(string(b), " c\n") {
+		t.Errorf("new file got %q, want c", b)
+	}
+	if fns, _ := filepath.Glob(prefix + "*"); len(fns) != 2 {
+		t.Errorf("got files %v, want 2", fns)
+	}
+}
+
+func TestRotateLoggerSIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+	fn := prefix + ".log"
+
+	rl := newRotateLogger(prefix, RotateOptions{Reopen: true})
+	defer rl.Close()
+	if e := os.Rename(fn, fn+".1"); e != nil {
+		t.Fatal(e)
+	}
+	p, _ := os.FindProcess(os.Getpid())
+	if e := p.Signal(syscall.SIGHUP); e != nil {
+		t.Skipf("send SIGHUP err=%v", e)
+	}
+	for i := 0; i < 100; i++ {
+		if _, e := os.Stat(fn); e == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("file=%s not reopened on SIGHUP", fn)
+}
+
+func TestRotateLoggerFailure(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "sub", "app")
+
+	errc := make(chan int, 10)
+	onError := func(e error, failures int) {
+		errc <- failures
+	}
+	rl := newRotateLogger(prefix, RotateOptions{OnError: onError})
+	defer rl.Close()
+	if rl.f != nil || rl.failures != 1 {
+		t.Fatalf("got file=%v failures=%d, want stderr", rl.f, rl.failures)
+	}
+	if n := <-errc; n != 1 {
+		t.Errorf("onError failures got %d, want 1", n)
+	}
+	rl.Log(&Record{Msg: "to stderr"}) // before retryAt
+
+	rl.retryAt = time.Now()
+	rl.Log(&Record{Msg: "retry"})
+	if n := <-errc; n != 2 || rl.backoff != 2*logRetryMin {
+		t.Errorf("retry got failures=%d backoff=%v", n, rl.backoff)
+	}
+
+	if e := os.Mkdir(filepath.Dir(prefix), 0755); e != nil {
+		t.Fatal(e)
+	}
+	rl.retryAt = time.Now()
+	rl.Log(&Record{Msg: "to file"})
+	rl.Flush()
+	if rl.f == nil || rl.failures != 0 {
+		t.Fatalf("got file=%v failures=%d, want file", rl.f, rl.failures)
+	}
+	b, e := ioutil.ReadFile(rl.fn)
+	if e != nil || !strings.HasSuffix(string(b), " to file\n") {
+		t.Errorf("read file got %q err=%v", b, e)
+	}
+}
+
+func TestRotateLoggerFailWrite(t *testing.T) {
+	dir := t.TempDir()
+	stderr, e := os.Create(filepath.Join(dir, "stderr"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer func(old *os.File) {
+		os.Stderr = old
+	}(os.Stderr)
+	os.Stderr = stderr
+
+	rl := newRotateLogger(filepath.Join(dir, "app"), RotateOptions{})
+	defer rl.Close()
+	rl.Log(&Record{Msg: "buffered"})
+	rl.f.Close() // the writes fail
+	rl.Flush()
+	if rl.f != nil || rl.wbuf.Len() != 0 {
+		t.Fatalf("got file=%v buffered=%d, want stderr", rl.f, rl.wbuf.Len())
+	}
+	rl.Log(&Record{Msg: "degraded"})
+
+	b, e := ioutil.ReadFile(stderr.Name())
+	if e != nil || !strings.Contains(string(b), " buffered\n") || !strings.HasSuffix(string(b), " degraded\n") {
+		t.Errorf("stderr got %q err=%v", b, e)
+	}
+}
+
+func TestRotateLoggerHeader(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+
+	rl := newRotateLogger(prefix, RotateOptions{Formatter: JSONFormatter})
+	defer rl.Close()
+	first := rl.fn
+	rl.Log(&Record{Msg: "a"})
+	rl.rotate()
+	rl.Flush()
+
+	b, e := ioutil.ReadFile(rl.fn)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var h map[string]interface{}
+	if e := json.Unmarshal(b, &h); e != nil {
+		t.Fatalf("header %q err=%v", b, e)
+	}
+	if h["msg"] != "log file header" || h["prev"] != first ||
+		h["pid"] != float64(os.Getpid()) || h["host"] != hostname {
+		t.Errorf("header got %v", h)
+	}
+	if rl.nbytes != 0 {
+		t.Errorf("nbytes got %d, want 0", rl.nbytes)
+	}
+}
+
+func TestRotateLoggerClose(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+
+	rl := newRotateLogger(prefix, RotateOptions{MaxSize: 10, Compress: true})
+	defer rl.Close()
+	for i := 0; i < 3; i++ {
+		rl.Log(&Record{Msg: "0123456789"})
+	}
+	fn := rl.fn
+	if e := rl.Close(); e != nil {
+		t.Fatalf("close err=%v", e)
+	}
+	// the pending compression is done by Close
+	if got := listNames(t, prefix); len(got) != 3 || got[0] != filepath.Base(fn) ||
+		filepath.Ext(got[1]) != ".gz" || filepath.Ext(got[2]) != ".gz" {
+		t.Errorf("got %v, want the current log and 2 gz", got)
+	}
+	b, e := ioutil.ReadFile(fn)
+	if e != nil || !strings.HasSuffix(string(b), " 0123456789\n") {
+		t.Errorf("read file got %q err=%v", b, e)
+	}
+	rl.Log(&Record{Msg: "dropped"})
+	rl.Flush()
+	if e := rl.Close(); e != nil {
+		t.Errorf("close again err=%v", e)
+	}
+}
@@ -0,0 +1,65 @@
+package vlog
+
+// severities are the file sets of severityLogger, from the least verbose.
+var severities = []struct {
+	name string
+	min  Level // the minimum level of the records in the set
+}{
+	{"ERROR", err},
+	{"WARNING", warn},
+	{"INFO", info},
+	{"V", v2},
+}
+
+// severityLogger writes a set of rotated files per severity.
+type severityLogger struct {
+	lgs []*rotateLogger // in the order of severities
+}
+
+func newSeverityLogger(prefix string, opts RotateOptions) *severityLogger {
+	sl := &severityLogger{}
+	symlink := opts.Symlink
+	for _, sev := range severities {
+		if symlink != "" {
+			opts.Symlink = symlink + "." + sev.name
+		}
+		sl.lgs = append(sl.lgs, newRotateLogger(prefix+"."+sev.name, opts))
+	}
+	return sl
+}
+
+func (sl *severityLogger) Log(r *Record) {
+	for i, sev := range severities {
+		if r.Level >= sev.min {
+			sl.lgs[i].Log(r)
+		}
+	}
+}
+
+func (sl *severityLogger) Flush() {
+	for _, rl := range sl.lgs {
+		rl.Flush()
+	}
+}
+
+// Reopen reopens the files of all severities in the Reopen mode.
+func (sl *severityLogger) Reopen() error {
+	var first error
+	for _, rl := range sl.lgs {
+		if e := rl.Reopen(); e != nil && first == nil {
+			first = e
+		}
+	}
+	return first
+}
+
+// Close closes the files of all severities.
+func (sl *severityLogger) Close() error {
+	var first error
+	for _, rl := range sl.lgs {
+		if e := rl.Close(); e != nil && first == nil {
+			first = e
+		}
+	}
+	return first
+}
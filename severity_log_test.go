@@ -0,0 +1,49 @@
+package vlog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSeverityLogger(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "app")
+
+	sl := NewRotateLogger(prefix, RotateOptions{PerSeverity: true, Symlink: prefix})
+	defer sl.Close()
+	for _, l := range []Level{err, warn, info, v1, v2} {
+		sl.Log(&Record{Level: l, Msg: "msg-" + l.String()})
+	}
+	sl.Flush()
+
+	testcases := []struct {
+		sev  string
+		want []string
+	}{
+		{"ERROR", []string{"err"}},
+		{"WARNING", []string{"err", "warn"}},
+		{"INFO", []string{"err", "warn", "info"}},
+		{"V", []string{"err", "warn", "info", "v1", "v2"}},
+	}
+	for _, tc := range testcases {
+		fns, _ := filepath.Glob(prefix + "." + tc.sev + ".*.log")
+		if len(fns) != 1 {
+			t.Fatalf("%s: got files %v, want 1", tc.sev, fns)
+		}
+		b, e := ioutil.ReadFile(prefix + "." + tc.sev) // the symlink
+		if e != nil {
+			t.Fatalf("%s: read err=%v", tc.sev, e)
+		}
+		var got []string
+		for _, ln := range strings.Split(string(b), "\n") {
+			if i := strings.Index(ln, " msg-"); i >= 0 {
+				got = append(got, ln[i+len(" msg-"):])
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: got %v, want %v", tc.sev, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package vlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// NewSlogHandler returns a slog.Handler that logs with vlog.
+// Whether a slog level is enabled is decided by the Level variable
+// with the given name, as if it is returned by NewNamed(name),
+// so the slog output is controlled by -vlog too.
+//
+// The slog levels are mapped to vlog levels as,
+//   - LevelError and above is err
+//   - LevelWarn and above is warn
+//   - LevelInfo and above is info
+//   - LevelDebug and above is v1
+//   - below LevelDebug is v2
+//
+// The attributes are logged as Fields. The key of an attribute in
+// a group is prefixed with the group name and ".".
+func NewSlogHandler(name string) slog.Handler {
+	_, fn, _, _ := runtime.Caller(1)
+	return &slogHandler{v: newNamed(name, fn)}
+}
+
+type slogHandler struct {
+	v      *Level
+	fields []Field // from WithAttrs
+	group  string  // key prefix from WithGroup, ends with "."
+}
+
+func slogLevel(l slog.Level) Level {
+	switch {
+	case l >= slog.LevelError:
+		return err
+	case l >= slog.LevelWarn:
+		return warn
+	case l >= slog.LevelInfo:
+		return info
+	case l >= slog.LevelDebug:
+		return v1
+	default:
+		return v2
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return h.v.get() <= slogLevel(l)
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	fs := make([]Field, len(h.fields), len(h.fields)+r.NumAttrs())
+	copy(fs, h.fields)
+	r.Attrs(func(a slog.Attr) bool {
+		fs = appendAttr(fs, h.group, a)
+		return true
+	})
+	lr := &Record{Time: r.Time, Level: slogLevel(r.Level), Name: h.v.name(), Msg: r.Message, Fields: fs}
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		lr.File, lr.Line = f.File, f.Line
+	}
+	logger().Log(lr)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.fields = make([]Field, len(h.fields), len(h.fields)+len(as))
+	copy(h2.fields, h.fields)
+	for _, a := range as {
+		h2.fields = appendAttr(h2.fields, h.group, a)
+	}
+	return &h2
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+func appendAttr(fs []Field, group string, a slog.Attr) []Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fs = appendAttr(fs, group, ga)
+		}
+		return fs
+	}
+	if a.Key == "" {
+		return fs
+	}
+	return append(fs, Field{Key: group + a.Key, Value: v.Any()})
+}
@@ -0,0 +1,52 @@
+package vlog
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	b := testLogger(t)
+
+	sl := slog.New(NewSlogHandler("test/slog"))
+	setLevels("test/slog=w")
+	sl.Info("info msg")
+	if b.Len() != 0 {
+		t.Errorf("Info at warn level got %q", b)
+	}
+	sl.Warn("warn msg", "a", 1)
+	if got, want := b.String(), " W test/slog slog_test.go:"; !strings.Contains(got, want) {
+		t.Errorf("Warn got %q, want caller %q", got, want)
+	}
+	if got, want := b.String(), ": warn msg a=1\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("Warn got %q, want suffix %q", got, want)
+	}
+
+	setLevels("test/*=v2")
+	b.Reset()
+	sl.With("a", 1).WithGroup("g").Log(nil, slog.LevelDebug-4, "v2 msg", "b", 2, slog.Group("h", "c", 3))
+	if got, want := b.String(), ": v2 msg a=1 g.b=2 g.h.c=3\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("v2 got %q, want suffix %q", got, want)
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	testcases := []struct {
+		in  slog.Level
+		out Level
+	}{
+		{slog.LevelError + 1, err},
+		{slog.LevelError, err},
+		{slog.LevelWarn, warn},
+		{slog.LevelInfo, info},
+		{slog.LevelInfo - 1, v1},
+		{slog.LevelDebug, v1},
+		{slog.LevelDebug - 1, v2},
+	}
+	for i, tc := range testcases {
+		if got := slogLevel(tc.in); got != tc.out {
+			t.Errorf("%d:%v: got %v, want %v", i, tc.in, got, tc.out)
+		}
+	}
+}
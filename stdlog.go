@@ -0,0 +1,59 @@
+package vlog
+
+import (
+	"log"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CaptureStdLog redirects the output of the standard log package to vlog,
+// so that log.Printf from other packages goes to the same Logger.
+// A line of the standard logger is logged at level if the Level variable
+// named name, as if it is returned by NewNamed(name), enables level.
+// For example, after
+//
+//	vlog.CaptureStdLog("stdlog", vlog.LevelInfo)
+//
+// -vlog=stdlog=e silences log.Printf.
+//
+// The flags of the standard logger are cleared, because vlog adds its
+// own time and caller.
+func CaptureStdLog(name string, level Level) {
+	_, fn, _, _ := runtime.Caller(1)
+	w := &stdLogWriter{v: newNamed(name, fn), level: level}
+	log.SetFlags(0)
+	log.SetOutput(w)
+}
+
+type stdLogWriter struct {
+	v     *Level
+	level Level
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	if w.v.get() > w.level {
+		return len(p), nil
+	}
+	r := &Record{Time: time.Now(), Level: w.level, Name: w.v.name(), Msg: strings.TrimSuffix(string(p), "\n")}
+	r.File, r.Line = stdLogCaller()
+	logger().Log(r)
+	return len(p), nil
+}
+
+// stdLogCaller returns the caller of the log function, the first frame
+// out of the log package above Write.
+func stdLogCaller() (string, int) {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip Callers, stdLogCaller and Write
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "log.") {
+			return f.File, f.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
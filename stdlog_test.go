@@ -0,0 +1,32 @@
+package vlog
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCaptureStdLog(t *testing.T) {
+	b := testLogger(t)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	CaptureStdLog("stdlog", LevelWarn)
+	log.Printf("hello %d", 1)
+	if got, want := b.String(), " W stdlog stdlog_test.go:"; !strings.Contains(got, want) {
+		t.Errorf("got %q, want caller %q", got, want)
+	}
+	if got, want := b.String(), ": hello 1\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, want suffix %q", got, want)
+	}
+
+	setLevels("stdlog=e")
+	b.Reset()
+	log.Print("silenced")
+	if b.Len() != 0 {
+		t.Errorf("got %q, want silenced", b)
+	}
+}
@@ -41,15 +41,23 @@ func Fprint(w io.Writer, args ...interface{}) {
 	fmt.Fprintln(w, s)
 }
 
+// logAndFlush logs r and flushes the Logger, before panicking or
+// exiting.
+func logAndFlush(r *Record) {
+	l := logger()
+	l.Log(r)
+	l.Flush()
+}
+
 // Panic formats args and panic.
 func Panic(args ...interface{}) {
-	lg.Log(Format(args...))
+	logAndFlush(newRecord(1, LevelErr, Format(args...), nil))
 	panic("panic")
 }
 
 // Fatal formats args and panic.
 func Fatal(args ...interface{}) {
-	lg.Log(Format(args...))
+	logAndFlush(newRecord(1, LevelErr, Format(args...), nil))
 	os.Exit(1)
 }
 
@@ -58,7 +66,7 @@ func CheckOK(c bool, args ...interface{}) {
 	if c {
 		return
 	}
-	lg.Log(Format(args...))
+	logAndFlush(newRecord(1, LevelErr, Format(args...), nil))
 	panic("CHECK failure")
 }
 
@@ -67,7 +75,7 @@ func Check(err error, args ...interface{}) {
 	if err == nil {
 		return
 	}
-	lg.Log(Format(args...))
+	logAndFlush(newRecord(1, LevelErr, Format(args...), nil))
 	panic("CHECK error:" + err.Error())
 }
 
@@ -77,7 +85,7 @@ func CheckFlag(c bool, args ...interface{}) {
 	if c {
 		return
 	}
-	lg.Log(Format(args...))
+	logAndFlush(newRecord(1, LevelErr, Format(args...), nil))
 	flag.Usage()
 	os.Exit(2)
 }
@@ -91,7 +99,7 @@ func Must(result interface{}, err error, args ...interface{}) interface{} {
 	if err == nil {
 		return result
 	}
-	lg.Log(Format(args...))
+	logAndFlush(newRecord(1, LevelErr, Format(args...), nil))
 	panic("CHECK error:" + err.Error())
 }
 
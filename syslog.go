@@ -0,0 +1,187 @@
+package vlog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SyslogFormat is the message format of a syslog Logger.
+type SyslogFormat int
+
+const (
+	// SyslogRFC5424 is the syslog protocol in RFC 5424.
+	SyslogRFC5424 SyslogFormat = iota
+	// SyslogRFC3164 is the BSD syslog format in RFC 3164, which
+	// older daemons expect.
+	SyslogRFC3164
+)
+
+// SyslogOptions configures the Logger returned by NewSyslogLogger.
+type SyslogOptions struct {
+	// Addr is the path of the unixgram socket of the syslog daemon.
+	// Default is "/dev/log".
+	Addr string
+
+	// Format is the message format. Default is SyslogRFC5424.
+	Format SyslogFormat
+
+	// Facility is the syslog facility, e.g. 3 for daemon and 16-23 for
+	// local0-local7. Default is 1 (user).
+	Facility int
+
+	// AppName is the name of the program. Default is the base name of
+	// os.Args[0].
+	AppName string
+}
+
+// NewSyslogLogger returns a Logger that sends the records to the local
+// syslog daemon. The levels are mapped to the syslog severities err(3),
+// warning(4), info(6) and debug(7) for v1 and v2.
+//
+// The Logger reconnects when a send fails, e.g. after the daemon is
+// restarted. The record is written to stderr if the daemon is still
+// unreachable.
+func NewSyslogLogger(opts SyslogOptions) (Logger, error) {
+	if opts.Addr == "" {
+		opts.Addr = "/dev/log"
+	}
+	if opts.Facility == 0 {
+		opts.Facility = 1
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	sl := &syslogLogger{opts: opts, pid: strconv.Itoa(os.Getpid())}
+	if e := sl.dial(); e != nil {
+		return nil, e
+	}
+	return sl, nil
+}
+
+type syslogLogger struct {
+	mu      sync.Mutex
+	opts    SyslogOptions
+	pid     string
+	conn    net.Conn
+	buf     bytes.Buffer
+	closed  bool
+	failing bool // the last send failed
+}
+
+func (sl *syslogLogger) Log(r *Record) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.closed {
+		return
+	}
+	sl.buf.Reset()
+	sl.format(&sl.buf, r)
+	e := sl.send()
+	if e == nil {
+		sl.failing = false
+		return
+	}
+	if !sl.failing {
+		sl.failing = true
+		fmt.Fprintf(os.Stderr, "vlog: syslog %s err=%v, log to stderr\n", sl.opts.Addr, e)
+	}
+	sl.buf.Reset()
+	TextFormatter.Format(&sl.buf, r)
+	os.Stderr.Write(sl.buf.Bytes())
+}
+
+// send sends the message in sl.buf, and reconnects and resends once
+// if it fails.
+func (sl *syslogLogger) send() error {
+	if sl.conn != nil {
+		if _, e := sl.conn.Write(sl.buf.Bytes()); e == nil {
+			return nil
+		}
+		sl.conn.Close()
+		sl.conn = nil
+	}
+	if e := sl.dial(); e != nil {
+		return e
+	}
+	_, e := sl.conn.Write(sl.buf.Bytes())
+	return e
+}
+
+func (sl *syslogLogger) dial() error {
+	c, e := net.Dial("unixgram", sl.opts.Addr)
+	if e != nil {
+		return e
+	}
+	sl.conn = c
+	return nil
+}
+
+// format writes r as a syslog message, e.g.
+//
+//	<14>1 2016-01-02T15:04:05.000000-07:00 host foo 123 - - foo/bar foo.go:12: cache miss key=k1
+//
+// or in RFC 3164,
+//
+//	<14>Jan  2 15:04:05 foo[123]: foo/bar foo.go:12: cache miss key=k1
+func (sl *syslogLogger) format(b *bytes.Buffer, r *Record) {
+	var tb [40]byte
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(sl.opts.Facility*8 + syslogSeverity(r.Level)))
+	b.WriteByte('>')
+	if sl.opts.Format == SyslogRFC3164 {
+		b.Write(recordTime(r).AppendFormat(tb[:0], time.Stamp))
+		b.WriteByte(' ')
+		b.WriteString(sl.opts.AppName)
+		b.WriteByte('[')
+		b.WriteString(sl.pid)
+		b.WriteString("]: ")
+	} else {
+		b.WriteString("1 ")
+		b.Write(recordTime(r).AppendFormat(tb[:0], timeFormat))
+		b.WriteByte(' ')
+		b.WriteString(hostname)
+		b.WriteByte(' ')
+		b.WriteString(sl.opts.AppName)
+		b.WriteByte(' ')
+		b.WriteString(sl.pid)
+		b.WriteString(" - - ") // no msgid and structured data
+	}
+	writeText(b, r)
+	if n := b.Len(); b.Bytes()[n-1] == '\n' {
+		b.Truncate(n - 1)
+	}
+}
+
+// syslogSeverity returns the syslog severity of l.
+func syslogSeverity(l Level) int {
+	switch {
+	case l >= err:
+		return 3
+	case l == warn:
+		return 4
+	case l == info:
+		return 6
+	default:
+		return 7
+	}
+}
+
+func (sl *syslogLogger) Flush() {}
+
+func (sl *syslogLogger) Close() error {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	sl.closed = true
+	if sl.conn == nil {
+		return nil
+	}
+	e := sl.conn.Close()
+	sl.conn = nil
+	return e
+}
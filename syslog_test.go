@@ -0,0 +1,79 @@
+package vlog
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenSyslog(t *testing.T, addr string) *net.UnixConn {
+	t.Helper()
+	os.Remove(addr)
+	l, e := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	return l
+}
+
+func readSyslog(t *testing.T, l *net.UnixConn) string {
+	t.Helper()
+	b := make([]byte, 4096)
+	l.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, e := l.Read(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return string(b[:n])
+}
+
+func TestSyslogLogger(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log")
+	l := listenSyslog(t, addr)
+	defer l.Close()
+
+	sl, e := NewSyslogLogger(SyslogOptions{Addr: addr, AppName: "foo"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sl.Close()
+	pid := sl.(*syslogLogger).pid
+
+	r := &Record{Level: err, Name: "foo/bar", File: "/a/foo.go", Line: 12, Msg: "hello", Fields: []Field{{"k", "v"}}}
+	sl.Log(r)
+	got := readSyslog(t, l)
+	if !strings.HasPrefix(got, "<11>1 ") || !strings.HasSuffix(got, " "+hostname+" foo "+pid+" - - foo/bar foo.go:12: hello k=v") {
+		t.Errorf("rfc5424 got %q", got)
+	}
+
+	// daemon restarts
+	l.Close()
+	l = listenSyslog(t, addr)
+	r.Level = v1
+	sl.Log(r)
+	if got := readSyslog(t, l); !strings.HasPrefix(got, "<15>1 ") {
+		t.Errorf("after restart got %q", got)
+	}
+}
+
+func TestSyslogLoggerRFC3164(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log")
+	l := listenSyslog(t, addr)
+	defer l.Close()
+
+	sl, e := NewSyslogLogger(SyslogOptions{Addr: addr, Format: SyslogRFC3164, Facility: 16, AppName: "foo"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sl.Close()
+
+	tm := time.Date(2016, 1, 2, 15, 4, 5, 0, time.Local)
+	sl.Log(&Record{Time: tm, Level: warn, Msg: "hello\n"})
+	want := "<132>Jan  2 15:04:05 foo[" + sl.(*syslogLogger).pid + "]: * hello"
+	if got := readSyslog(t, l); got != want {
+		t.Errorf("rfc3164 got %q want %q", got, want)
+	}
+}
@@ -1,5 +1,5 @@
 // Packet vlog provides package level verbose logging.
-// The actual logging is done with the standard log package.
+// The log records are written by a Logger, to stderr by default.
 //
 // To define a package level logging variable,
 //   var v = vlog.New()
@@ -7,13 +7,16 @@
 // To log a message at INFO level,
 //   v.I("a")
 //
+// To log a message with key/value pairs at INFO level,
+//   v.Iw("cache miss", "key", k, "shard", n)
+//
 // The logging level can be set with either the flag -vlog or
 // the environment variable GO_VLOG.
 //
 // The -vlog or GO_VLOG format is,
 //  k=v(,k=v)*
 //  k can be exact match like "foo/bar" or prefix match like "foo/*".
-//  v can be w|i|v1|v2
+//  v can be e|w|i|v1|v2
 // Default level can be set with prefix match "*".
 package vlog
 
@@ -22,14 +25,17 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"path"
 	"runtime"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 //go:generate stringer -type=Level
@@ -39,101 +45,189 @@ const (
 	v2 Level = -2 + iota
 	v1
 	info
+	warn
 	err
 )
 
+// Levels for the APIs that take a Level, like CaptureStdLog.
+const (
+	LevelV2   = v2
+	LevelV1   = v1
+	LevelInfo = info
+	LevelWarn = warn
+	LevelErr  = err
+)
+
 // E logs error message.
 // If args[0] is a format string, args is formatted with Printf,
 // otherwise args is formatted with Println.
 func (v *Level) E(args ...interface{}) {
-	if *v <= err {
-		lg.Log("E " + Format(args...))
+	if v.get() <= err {
+		logger().Log(v.record(1, err, Format(args...), nil))
 	}
 }
 
 func E(args ...interface{}) {
-	if levelVars[0].Level <= err {
-		lg.Log("E " + Format(args...))
+	if defaultVar.Level.get() <= err {
+		logger().Log(newRecord(1, err, Format(args...), nil))
+	}
+}
+
+// W logs warning message.
+func (v *Level) W(args ...interface{}) {
+	if v.get() <= warn {
+		logger().Log(v.record(1, warn, Format(args...), nil))
+	}
+}
+
+func W(args ...interface{}) {
+	if defaultVar.Level.get() <= warn {
+		logger().Log(newRecord(1, warn, Format(args...), nil))
 	}
 }
 
 // I logs info message.
 func (v *Level) I(args ...interface{}) {
-	if *v <= info {
-		lg.Log(Format(args...))
+	if v.get() <= info {
+		logger().Log(v.record(1, info, Format(args...), nil))
 	}
 }
 
 func I(args ...interface{}) {
-	if levelVars[0].Level <= info {
-		lg.Log(Format(args...))
+	if defaultVar.Level.get() <= info {
+		logger().Log(newRecord(1, info, Format(args...), nil))
 	}
 }
 
 // V1 logs verbose level 1 message.
 func (v *Level) V1(args ...interface{}) {
-	if *v <= v1 {
-		lg.Log(Format(args...))
+	if v.get() <= v1 {
+		logger().Log(v.record(1, v1, Format(args...), nil))
 	}
 }
 
 func V1(args ...interface{}) {
-	if levelVars[0].Level <= v1 {
-		lg.Log(Format(args...))
+	if defaultVar.Level.get() <= v1 {
+		logger().Log(newRecord(1, v1, Format(args...), nil))
 	}
 }
 
 // V2 logs verbose level 2 message.
 func (v *Level) V2(args ...interface{}) {
-	if *v <= v2 {
-		lg.Log(Format(args...))
+	if v.get() <= v2 {
+		logger().Log(v.record(1, v2, Format(args...), nil))
 	}
 }
 
 func V2(args ...interface{}) {
-	if levelVars[0].Level <= v2 {
-		lg.Log(Format(args...))
+	if defaultVar.Level.get() <= v2 {
+		logger().Log(newRecord(1, v2, Format(args...), nil))
+	}
+}
+
+// Ew logs error message msg with key/value pairs kvs, like
+//  v.Ew("write failed", "file", fn, "err", err)
+// A key is usually a string. A Field in kvs is taken as a key/value pair.
+func (v *Level) Ew(msg string, kvs ...interface{}) {
+	if v.get() <= err {
+		logger().Log(v.record(1, err, msg, fields(kvs)))
+	}
+}
+
+func Ew(msg string, kvs ...interface{}) {
+	if defaultVar.Level.get() <= err {
+		logger().Log(newRecord(1, err, msg, fields(kvs)))
+	}
+}
+
+// Ww logs warning message msg with key/value pairs kvs.
+func (v *Level) Ww(msg string, kvs ...interface{}) {
+	if v.get() <= warn {
+		logger().Log(v.record(1, warn, msg, fields(kvs)))
+	}
+}
+
+func Ww(msg string, kvs ...interface{}) {
+	if defaultVar.Level.get() <= warn {
+		logger().Log(newRecord(1, warn, msg, fields(kvs)))
+	}
+}
+
+// Iw logs info message msg with key/value pairs kvs.
+func (v *Level) Iw(msg string, kvs ...interface{}) {
+	if v.get() <= info {
+		logger().Log(v.record(1, info, msg, fields(kvs)))
+	}
+}
+
+func Iw(msg string, kvs ...interface{}) {
+	if defaultVar.Level.get() <= info {
+		logger().Log(newRecord(1, info, msg, fields(kvs)))
+	}
+}
+
+// V1w logs verbose level 1 message msg with key/value pairs kvs.
+func (v *Level) V1w(msg string, kvs ...interface{}) {
+	if v.get() <= v1 {
+		logger().Log(v.record(1, v1, msg, fields(kvs)))
+	}
+}
+
+func V1w(msg string, kvs ...interface{}) {
+	if defaultVar.Level.get() <= v1 {
+		logger().Log(newRecord(1, v1, msg, fields(kvs)))
+	}
+}
+
+// V2w logs verbose level 2 message msg with key/value pairs kvs.
+func (v *Level) V2w(msg string, kvs ...interface{}) {
+	if v.get() <= v2 {
+		logger().Log(v.record(1, v2, msg, fields(kvs)))
+	}
+}
+
+func V2w(msg string, kvs ...interface{}) {
+	if defaultVar.Level.get() <= v2 {
+		logger().Log(newRecord(1, v2, msg, fields(kvs)))
 	}
 }
 
 // Vstack logs the message and the stacktrace of this goroutine.
 // It is noop when verbose logging is not enabled.
 func (v *Level) Vstack(args ...interface{}) {
-	if *v >= info {
+	if v.get() >= info {
 		return
 	}
 	s := Format(args...)
-	lg.Log(stackTrace(s))
+	logger().Log(v.record(1, v1, stackTrace(s), nil))
 }
 
 func Vstack(args ...interface{}) {
-	levelVars[0].Level.Vstack(args...)
+	defaultVar.Level.Vstack(args...)
 }
 
 // On returns true if the specific verbose level 1-3 is enabled.
 func (v *Level) On(l int) bool {
 	lv := Level(-l)
-	return *v <= lv
+	return v.get() <= lv
 }
 
 func On(l int) bool {
-	return levelVars[0].Level.On(l)
+	return defaultVar.Level.On(l)
 }
 
 // Vset sets the verbose logging level.
 func (v *Level) Vset(l int) Level {
 	lv := Level(-l)
 	if lv < v2 || lv >= info {
-		lg.Log(Format("invalid verbose level=%d", l))
-		return *v
+		logger().Log(newRecord(0, warn, Format("invalid verbose level=%d", l), nil))
+		return v.get()
 	}
-	old := *v
-	atomic.StoreInt32((*int32)(v), int32(lv))
-	return old
+	return Level(atomic.SwapInt32((*int32)(v), int32(lv)))
 }
 
 func Vset(l int) Level {
-	return levelVars[0].Level.Vset(l)
+	return defaultVar.Level.Vset(l)
 }
 
 // Error returns an error. The message of the error is formatted
@@ -145,12 +239,12 @@ func (v *Level) Error(args ...interface{}) error {
 }
 
 func Error(args ...interface{}) error {
-	return levelVars[0].Level.Error(args...)
+	return defaultVar.Level.Error(args...)
 }
 
 // newError is necessary to get the correct call stack
 func (v *Level) newError(s string) error {
-	switch *v {
+	switch v.get() {
 	default:
 		return errors.New(s)
 
@@ -166,24 +260,43 @@ func (v *Level) newError(s string) error {
 	}
 }
 
-func parseLevel(lvs string) Level {
+// get loads the level atomically, so that it can be changed by
+// SetLevels while other goroutines are logging.
+func (v *Level) get() Level {
+	return Level(atomic.LoadInt32((*int32)(v)))
+}
+
+func (v *Level) set(l Level) {
+	atomic.StoreInt32((*int32)(v), int32(l))
+}
+
+func parseLevel(lvs string) (Level, error) {
 	switch strings.ToLower(lvs) {
 	case "2", "v2":
-		return v2
+		return v2, nil
 	case "1", "v1":
-		return v1
+		return v1, nil
 	case "i", "info":
-		return info
+		return info, nil
+	case "w", "warn":
+		return warn, nil
 	case "e", "err":
-		return err
+		return err, nil
 	default:
-		lg.Log(Format("ignore invalid logging level=%s", lvs))
-		return info
+		return info, fmt.Errorf("invalid logging level=%s", lvs)
 	}
 }
 
 // New returns a vlog Level variable.
-// The name of the Level variable is inferred with these rules,
+// The name of the Level variable is the import path of the caller's
+// package, e.g. "github.com/foo/bar", with these rules,
+//  - the import path of package main is the path of the main package
+//    in the build info of the binary.
+//  - if the package is "<foo_pkg>/{main,cmd}" and the file is bar.go,
+//    name is "foo_pkg/bar".
+//
+// If the import path is not available, the name is inferred from the
+// file name of the caller,
 //  - file name of the caller must be under "/src/", to follow go path convention
 //  - if the file is ".../src/<foo_pkg>/{main,cmd}/bar.go", name is "foo_pkg/bar".
 //  - if the file is ".../src/<foo_pkg>/bar.go", name is "foo_pkg".
@@ -191,23 +304,47 @@ func parseLevel(lvs string) Level {
 // New must be called before Parse() is callled.
 func New() *Level {
 	// Note: 1 to skip New
-	_, fn, _, ok := runtime.Caller(1)
+	pc, fn, _, ok := runtime.Caller(1)
 	if !ok {
-		lg.Log("fail to get file from runtime.caller")
-		return &levelVars[0].Level // [0] is default
+		logger().Log(newRecord(0, warn, "fail to get file from runtime.caller", nil))
+		return &defaultVar.Level // [0] is default
 	}
-	name := inferName(fn)
+	var name string
+	if f := runtime.FuncForPC(pc); f != nil {
+		name = inferPkgName(f.Name(), fn, mainPath)
+	}
+	if name == "" {
+		name = inferName(fn)
+	}
+	return newVar(name, fn)
+}
+
+// NewNamed returns a vlog Level variable with the given name,
+// like "mylib/cache". Unlike New, the name does not depend on the
+// package path or file layout of the caller. The name is matched
+// by -vlog the same way as the name inferred by New.
+//
+// NewNamed must be called before Parse() is callled.
+func NewNamed(name string) *Level {
+	_, fn, _, _ := runtime.Caller(1)
+	return newNamed(name, fn)
+}
+
+func newNamed(name, fn string) *Level {
+	name = strings.Trim(strings.ToLower(name), "/")
 	return newVar(name, fn)
 }
 
 func newVar(name, fn string) *Level {
 	if name == "" {
-		lg.Log(Format("fail to infer name from file=%s", fn))
-		return &levelVars[0].Level // [0] is default
+		logger().Log(newRecord(0, warn, Format("fail to get name for file=%s", fn), nil))
+		return &defaultVar.Level // [0] is default
 	}
+	levelMu.Lock()
 	for _, lv := range levelVars {
 		if lv.Name == name {
-			lg.Log(Format("dup level name=%s inferred from file=%s", name, fn))
+			levelMu.Unlock() // lg may call printLevelVars
+			logger().Log(newRecord(0, warn, Format("dup level name=%s from file=%s", name, fn), nil))
 			return &lv.Level
 		}
 	}
@@ -216,6 +353,8 @@ func newVar(name, fn string) *Level {
 		File: fn,
 	}
 	levelVars = append(levelVars, lv)
+	levelNames.Store(&lv.Level, name)
+	levelMu.Unlock()
 	return &lv.Level
 }
 
@@ -239,6 +378,43 @@ func inferName(fn string) string {
 	return strings.TrimRight(dn, "/")
 }
 
+// mainPath is the import path of package main, or "" if unknown.
+var mainPath = func() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok || bi.Path == "command-line-arguments" {
+		return ""
+	}
+	return bi.Path
+}()
+
+// inferPkgName infers name from the import path of the package of function
+// fname, e.g. "github.com/foo/bar.init" or "github.com/foo/bar.(*T).M".
+// fn is the file of the function.
+func inferPkgName(fname, fn, mainPath string) string {
+	if i := strings.Index(fname, "["); i >= 0 {
+		fname = fname[:i] // type parameters may contain "/" and "."
+	}
+	i := strings.LastIndex(fname, "/")
+	j := strings.Index(fname[i+1:], ".")
+	if j < 0 {
+		return ""
+	}
+	pkg := fname[:i+1+j]
+	if pkg == "main" {
+		pkg = mainPath
+	}
+	if pkg == "" {
+		return ""
+	}
+	// The last element of an import path is escaped in function names.
+	pkg = strings.ToLower(strings.Replace(pkg, "%2e", ".", -1))
+	switch path.Base(pkg) {
+	case "main", "cmd":
+		return path.Join(path.Dir(pkg), strings.TrimSuffix(path.Base(strings.ToLower(fn)), ".go"))
+	}
+	return pkg
+}
+
 type levelVar struct {
 	Name  string
 	File  string
@@ -249,64 +425,126 @@ func (lv *levelVar) String() string {
 	return fmt.Sprintf("%s@%s", lv.Name, lv.File)
 }
 
-var levelVars = []*levelVar{&levelVar{}} // default level
+var (
+	// levelMu guards levelVars against concurrent New and SetLevels.
+	// The Level values are accessed atomically, not under levelMu.
+	levelMu   sync.Mutex
+	levelVars = []*levelVar{&defaultVar} // [0] is default
+
+	// defaultVar is the default level, used by the package-level
+	// functions without levelMu.
+	defaultVar levelVar
+
+	// levelNames maps *Level to the name of its levelVar,
+	// for the name of the records logged by the Level.
+	levelNames sync.Map
+)
+
+// name returns the name of the Level variable, "" for the default.
+func (v *Level) name() string {
+	if n, ok := levelNames.Load(v); ok {
+		return n.(string)
+	}
+	return ""
+}
+
+// The flags registered to flag.CommandLine by Parse.
+var (
+	flagOnce   sync.Once
+	flagConfig *Config
+	flagHelp   *bool
+)
 
+// Parse registers the vlog flags to flag.CommandLine, calls flag.Parse
+// and initializes vlog from the flags. The flags are,
+//  -vlog: vlog settings, k=v(,k=v)*
+//  -vlogfile, -vlogformat, -vlogmaxsize, -vlogflush: see RegisterFlags
+//  -vloghelp: show vlog setting and flag help
+//
+// The flags are registered by Parse, not when the package is
+// initialized. A program that calls flag.Parse before Parse must call
+// RegisterFlags(flag.CommandLine) before flag.Parse, and Init after it,
+// instead of Parse. A test run with "go test -args -vlog=..." must call
+// Parse in TestMain.
+//
+// A program that does not use the flag package can call Init instead.
 func Parse() {
+	flagOnce.Do(func() {
+		flagConfig = RegisterFlags(flag.CommandLine)
+		flagHelp = flag.Bool("vloghelp", false, "show vlog setting and flag help")
+	})
 	flag.Parse()
-	setLevels(*vlogFlag)
-	if *vlogHelp {
-		lg.Log("vlog setting:" + printLevelVars())
+	c := flagConfig
+	if e := setLevels(c.Levels); e != nil {
+		CheckFlag(false, "invalid -vlog=%s err=%v", c.Levels, e)
+	}
+	if *flagHelp {
+		logAndFlush(newRecord(0, info, "vlog setting:"+printLevelVars(), nil))
 		flag.Usage()
 		os.Exit(2)
 	}
-	if *vlogFile != "" {
-		lg = newRotateLogger(*vlogFile)
-	}
+	Init(*c) // levels are checked
 }
 
 func ParseEnv() {
 	if val := os.Getenv("GO_VLOG"); val != "" { // for testing
-		setLevels(val)
-		lg.Log(printLevelVars())
+		if e := setLevels(val); e != nil {
+			logger().Log(newRecord(0, warn, Format("ignore invalid GO_VLOG=%s err=%v", val, e), nil))
+			return
+		}
+		logger().Log(newRecord(0, info, printLevelVars(), nil))
 	}
 }
 
-// TODO: set level from a string at runtime
-func setLevels(value string) {
-	exact, prefix := parseFlag(value)
+// SetLevels sets the levels of all Level variables from spec at runtime.
+// spec has the same format as the -vlog flag. Levels not matched by spec
+// are reset to the default level. If spec is malformed, SetLevels returns
+// an error and no level is changed.
+//
+// SetLevels is safe to call while other goroutines are logging.
+func SetLevels(spec string) error {
+	return setLevels(spec)
+}
+
+func setLevels(value string) error {
+	exact, prefix, e := parseFlag(value)
+	if e != nil {
+		return e
+	}
+
+	levelMu.Lock()
+	defer levelMu.Unlock()
 	if v, ok := prefix["/"]; ok {
-		levelVars[0].Level = v // default level
+		defaultVar.Level.set(v) // default level
 	}
-	def := levelVars[0].Level
-	for _, lv := range levelVars[1:] {
-		lv.Level = def
+	def := defaultVar.Level.get()
+	prefixes := make([]string, 0, len(prefix))
+	for k := range prefix {
+		prefixes = append(prefixes, k)
 	}
-	if len(prefix) > 0 {
-		prefixes := make([]string, 0, len(prefix))
-		for k := range prefix {
-			prefixes = append(prefixes, k)
-		}
-		sort.Strings(prefixes)
-
-		for _, lv := range levelVars[1:] {
-			for i := len(prefixes) - 1; i >= 0; i-- {
-				k := prefixes[i]
-				// Match "foo" with "foo/" and "foo/bar" with "foo/"
-				if lv.Name == k[:len(k)-1] || strings.HasPrefix(lv.Name, k) {
-					lv.Level = prefix[k]
-					break
-				}
+	sort.Strings(prefixes)
+
+	// Compute the final level of each var before storing it, so that
+	// concurrent loggers never see an intermediate level.
+	for _, lv := range levelVars[1:] {
+		l := def
+		for i := len(prefixes) - 1; i >= 0; i-- {
+			k := prefixes[i]
+			// Match "foo" with "foo/" and "foo/bar" with "foo/"
+			if lv.Name == k[:len(k)-1] || strings.HasPrefix(lv.Name, k) {
+				l = prefix[k]
+				break
 			}
 		}
-	}
-	for _, lv := range levelVars[1:] {
 		if i, ok := exact[lv.Name]; ok {
-			lv.Level = i
+			l = i
 		}
+		lv.Level.set(l)
 	}
+	return nil
 }
 
-func parseFlag(value string) (exact, prefix map[string]Level) {
+func parseFlag(value string) (exact, prefix map[string]Level, e error) {
 	exact = make(map[string]Level)
 	prefix = make(map[string]Level)
 	s := value
@@ -319,20 +557,23 @@ func parseFlag(value string) (exact, prefix map[string]Level) {
 		}
 		j := strings.Index(k, "=")
 		if j < 0 {
-			panic(Format("malformed: no level", value))
+			return nil, nil, fmt.Errorf("malformed: no level in %q", k)
 		}
 		k, v := k[:j], k[j+1:]
-		lv := parseLevel(v)
+		lv, e := parseLevel(v)
+		if e != nil {
+			return nil, nil, e
+		}
 		k = strings.ToLower(k)
 		pre := false
 		if k == "*" || strings.HasSuffix(k, "/*") {
 			k = k[:len(k)-1]
 			if strings.Contains(k, "*") {
-				panic(Format("malformed: multiple star", s))
+				return nil, nil, fmt.Errorf("malformed: multiple star in %q", k+"*")
 			}
 			pre = true
 		} else if strings.Contains(k, "*") {
-			panic(Format("malformed: star in middle", s))
+			return nil, nil, fmt.Errorf("malformed: star in middle of %q", k)
 		}
 		k = strings.TrimRight(k, "/")
 		if pre {
@@ -341,43 +582,132 @@ func parseFlag(value string) (exact, prefix map[string]Level) {
 			exact[k] = lv
 		}
 	}
-	return exact, prefix
+	return exact, prefix, nil
+}
+
+// copyLevelVars returns a snapshot of levelVars.
+func copyLevelVars() []levelVar {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	lvs := make([]levelVar, len(levelVars))
+	for i, lv := range levelVars {
+		lvs[i] = levelVar{Name: lv.Name, File: lv.File, Level: lv.Level.get()}
+	}
+	return lvs
 }
 
 func printLevelVars() string {
+	levelMu.Lock()
+	defer levelMu.Unlock()
 	var b bytes.Buffer
-	fmt.Fprintf(&b, "*=%v", levelVars[0].Level)
+	fmt.Fprintf(&b, "*=%v", defaultVar.Level.get())
 	for _, lv := range levelVars[1:] {
-		fmt.Fprintf(&b, ",%s=%v", lv.Name, lv.Level)
+		fmt.Fprintf(&b, ",%s=%v", lv.Name, lv.Level.get())
 	}
 	return b.String()
 }
 
-var (
-	vlogFlag = flag.String("vlog", "", "vlog settings, k=v(,k=v)*")
-	vlogFile = flag.String("vlogfile", "", "vlog file prefix")
-	vlogHelp = flag.Bool("vloghelp", false, "show vlog setting and flag help")
-)
-
+// Logger writes log records.
+// Flush writes the buffered records to the underlying writer.
+// Close flushes and releases the resources of the Logger, like files
+// and goroutines. The records logged after Close may be dropped.
 type Logger interface {
-	Log(s string)
+	Log(r *Record)
 	Flush()
+	Close() error
 }
 
-type stderrLogger struct {
-	lg *log.Logger
+// NewWriterLogger returns a Logger that writes records formatted by f to w.
+func NewWriterLogger(w io.Writer, f Formatter) Logger {
+	return &writerLogger{w: w, f: f}
 }
 
-func (l *stderrLogger) Log(s string) {
-	l.lg.Output(3, s)
+type writerLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	f   Formatter
+	buf bytes.Buffer
 }
 
-func (l *stderrLogger) Flush() {}
+func (l *writerLogger) Log(r *Record) {
+	l.mu.Lock()
+	l.buf.Reset()
+	l.f.Format(&l.buf, r)
+	l.w.Write(l.buf.Bytes()) // ignore error
+	l.mu.Unlock()
+}
+
+func (l *writerLogger) Flush() {}
+
+// Close does not close the writer.
+func (l *writerLogger) Close() error { return nil }
+
+// lg holds the Logger, which should always be available. It is
+// replaced atomically, since other goroutines may be logging.
+var lg = func() *atomic.Pointer[Logger] {
+	p := new(atomic.Pointer[Logger])
+	l := NewWriterLogger(os.Stderr, TextFormatter)
+	p.Store(&l)
+	return p
+}()
 
-const logPrefix = log.Ldate | log.Lmicroseconds | log.Lshortfile
+// logger returns the Logger.
+func logger() Logger {
+	return *lg.Load()
+}
+
+// swapLogger sets the Logger to l, and returns the old Logger.
+func swapLogger(l Logger) Logger {
+	return *lg.Swap(&l)
+}
 
-// lg should always be available
-var lg Logger = &stderrLogger{lg: log.New(os.Stderr, "", logPrefix)}
+// Close closes the Logger, and resets the Logger to stderr.
+// Close should be called before the program exits, e.g.
+//   defer vlog.Close()
+func Close() error {
+	return swapLogger(NewWriterLogger(os.Stderr, TextFormatter)).Close()
+}
+
+// SetLogger sets the Logger to write log records to.
+// SetLogger should be called before logging, e.g. right after Parse.
+func SetLogger(l Logger) {
+	swapLogger(l)
+}
+
+// record returns a Record of level l logged by v.
+func (v *Level) record(depth int, l Level, msg string, fs []Field) *Record {
+	r := newRecord(depth+1, l, msg, fs)
+	r.Name = v.name()
+	return r
+}
+
+// Reopen reopens the log file if the Logger writes to a file that is
+// rotated by an external tool, see RotateOptions.Reopen.
+func Reopen() error {
+	return reopen(logger())
+}
+
+// reopen reopens l if l is a reopener.
+func reopen(l Logger) error {
+	if r, ok := l.(reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+// reopener is a Logger that reopens its log file.
+type reopener interface {
+	Reopen() error
+}
+
+// newRecord returns a Record of level l at the time of now. depth is
+// the number of frames to skip to get the caller, 0 is the caller of
+// newRecord.
+func newRecord(depth int, l Level, msg string, fs []Field) *Record {
+	r := &Record{Time: time.Now(), Level: l, Msg: msg, Fields: fs}
+	_, r.File, r.Line, _ = runtime.Caller(depth + 1)
+	return r
+}
 
 var stackTraceBegin = []byte("/vlog.go:")
 
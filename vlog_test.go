@@ -2,9 +2,10 @@ package vlog
 
 import (
 	"bytes"
-	"log"
+	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -26,9 +27,10 @@ func TestParseFlag(t *testing.T) {
 			},
 		},
 		{
-			"fe=e,fi=i,fv1=v1,fv2=v2",
+			"fe=e,fw=w,fi=i,fv1=v1,fv2=v2",
 			map[string]Level{
 				"fe":  err,
+				"fw":  warn,
 				"fi":  info,
 				"fv1": v1,
 				"fv2": v2,
@@ -38,7 +40,10 @@ func TestParseFlag(t *testing.T) {
 	}
 
 	for i, tc := range testcases {
-		exact, prefix := parseFlag(tc.in)
+		exact, prefix, e := parseFlag(tc.in)
+		if e != nil {
+			t.Errorf("%d:%v err: %v", i, tc, e)
+		}
 		if !reflect.DeepEqual(exact, tc.exact) {
 			t.Errorf("%d:%v exact: got %v, want %v", i, tc, exact, tc.exact)
 		}
@@ -48,6 +53,34 @@ func TestParseFlag(t *testing.T) {
 	}
 }
 
+func TestParseFlagError(t *testing.T) {
+	for i, in := range []string{"foo", "foo=x", "f*o=i", "*/foo/*=i", "a=i,b"} {
+		if _, _, e := parseFlag(in); e == nil {
+			t.Errorf("%d:%s: want err", i, in)
+		}
+	}
+}
+
+// testLogger resets the Level variables, and logs in text to the
+// returned buffer until the test ends.
+func testLogger(t *testing.T) *bytes.Buffer {
+	oldlvs, olddef := levelVars, defaultVar.Level.get()
+	resetLevelVars()
+	b := new(bytes.Buffer)
+	oldlg := swapLogger(NewWriterLogger(b, TextFormatter))
+	t.Cleanup(func() {
+		swapLogger(oldlg)
+		levelVars = oldlvs
+		defaultVar.Level.set(olddef)
+	})
+	return b
+}
+
+func resetLevelVars() {
+	defaultVar = levelVar{}
+	levelVars = []*levelVar{&defaultVar}
+}
+
 func clearFilenames() {
 	for _, lv := range levelVars {
 		lv.File = ""
@@ -71,6 +104,52 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewNamed(t *testing.T) {
+	testLogger(t)
+
+	va := NewNamed("MyLib/Cache/")
+	vb := NewNamed("mylib/cache")
+	if va != vb {
+		t.Errorf("dup name got different vars")
+	}
+	if len(levelVars) != 2 || levelVars[1].Name != "mylib/cache" ||
+		!strings.HasSuffix(levelVars[1].File, "vlog_test.go") {
+		t.Errorf("got %v", levelVars)
+	}
+	setLevels("mylib/*=v1")
+	if *va != v1 {
+		t.Errorf("prefix match got %v, want v1", va)
+	}
+	setLevels("mylib/cache=e")
+	if *va != err {
+		t.Errorf("exact match got %v, want err", va)
+	}
+}
+
+func TestNewNamedRace(t *testing.T) {
+	testLogger(t)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			NewNamed(fmt.Sprintf("race/%d", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			I("race", i)
+			Iw("race", "i", i)
+		}
+	}()
+	wg.Wait()
+	if len(levelVars) != 101 {
+		t.Errorf("got %d vars, want 101", len(levelVars))
+	}
+}
+
 func TestInferName(t *testing.T) {
 	testcases := []struct {
 		in  string
@@ -146,7 +225,7 @@ func TestSetLevels(t *testing.T) {
 		},
 	}
 	for i, tc := range testcases {
-		levelVars = []*levelVar{&levelVar{}}
+		resetLevelVars()
 		for _, n := range names {
 			newVar(n, "")
 		}
@@ -162,13 +241,51 @@ func TestSetLevels(t *testing.T) {
 	}
 }
 
+func TestInferPkgName(t *testing.T) {
+	testcases := []struct {
+		fname string
+		fn    string
+		main  string
+		out   string
+	}{
+		{"github.com/org/repo/pkg.init", "/x/pkg/foo.go", "", "github.com/org/repo/pkg"},
+		{"github.com/org/repo/pkg.(*T).M", "/x/pkg/foo.go", "", "github.com/org/repo/pkg"},
+		{"github.com/org/repo/pkg.F[...]", "/x/pkg/foo.go", "", "github.com/org/repo/pkg"},
+		{"gopkg.in/yaml%2ev2.init", "/x/yaml/foo.go", "", "gopkg.in/yaml.v2"},
+		{"github.com/Org/Repo/cmd.init", "/x/cmd/Bar.go", "", "github.com/org/repo/bar"},
+		{"main.init", "/x/cmd/foo/main.go", "github.com/org/repo/cmd/foo", "github.com/org/repo/cmd/foo"},
+		{"main.init", "/x/main/bar.go", "github.com/org/repo/main", "github.com/org/repo/bar"},
+		{"main.init", "/x/foo/main.go", "", ""},
+		{"init", "/x/foo/main.go", "", ""},
+	}
+	for i, tc := range testcases {
+		got := inferPkgName(tc.fname, tc.fn, tc.main)
+		if got != tc.out {
+			t.Errorf("%d:%v: got %v, want %v", i, tc, got, tc.out)
+		}
+	}
+}
+
+func TestSetLevelsError(t *testing.T) {
+	testLogger(t)
+	va := newVar("a", "")
+	if e := SetLevels("*=e,a=v1"); e != nil {
+		t.Fatalf("SetLevels err: %v", e)
+	}
+	if e := SetLevels("*=i,a=x"); e == nil {
+		t.Errorf("SetLevels want err")
+	}
+	if *va != v1 || defaultVar.Level != err {
+		t.Errorf("levels changed by invalid spec: %s", printLevelVars())
+	}
+}
+
 func TestLog(t *testing.T) {
-	levelVars = []*levelVar{&levelVar{}}
+	resetLevelVars()
 	b := new(bytes.Buffer)
-	oldlg := lg
-	lg = &stderrLogger{log.New(b, "", 0)}
+	oldlg := swapLogger(NewWriterLogger(b, TextFormatter))
 	defer func() {
-		lg = oldlg
+		swapLogger(oldlg)
 	}()
 
 	va := newVar("a", "")
@@ -176,7 +293,8 @@ func TestLog(t *testing.T) {
 	vabc := newVar("a/b/c", "")
 	vabd := newVar("a/b/d", "")
 	vc := newVar("c", "")
-	setLevels("*=e,a=i,a/b/*=v1,a/b/c=v2")
+	vw := newVar("w", "")
+	setLevels("*=e,a=i,a/b/*=v1,a/b/c=v2,w=w")
 	if *va != info {
 		t.Errorf("va got %v, want info", va)
 	}
@@ -192,9 +310,13 @@ func TestLog(t *testing.T) {
 	if *vc != err {
 		t.Errorf("vc got %v, want err", vc)
 	}
+	if *vw != warn {
+		t.Errorf("vw got %v, want warn", vw)
+	}
 
 	type fn func(*Level, ...interface{})
 	fnE := (*Level).E
+	fnW := (*Level).W
 	fnI := (*Level).I
 	fnV1 := (*Level).V1
 	fnV2 := (*Level).V2
@@ -217,11 +339,15 @@ func TestLog(t *testing.T) {
 		{vabd, fnV1, "vabdV1", true},
 		{vabd, fnV2, "vabdV2", false},
 		{vc, fnE, "vcE", true},
+		{vc, fnW, "vcW", false},
 		{vc, fnI, "vcI", false},
+		{vw, fnE, "vwE", true},
+		{vw, fnW, "vwW", true},
+		{vw, fnI, "vwI", false},
 		{vc, fnV1, "vcV1", false},
 	}
 	for i, tc := range testcases {
-		levelVars = []*levelVar{&levelVar{}}
+		resetLevelVars()
 		b.Reset()
 		tc.fn(tc.v, tc.m)
 		got := b.String()
@@ -237,10 +363,9 @@ func TestLog(t *testing.T) {
 
 func TestFormat(t *testing.T) {
 	b := new(bytes.Buffer)
-	oldlg := lg
-	lg = &stderrLogger{log.New(b, "", 0)}
+	oldlg := swapLogger(NewWriterLogger(b, TextFormatter))
 	defer func() {
-		lg = oldlg
+		swapLogger(oldlg)
 	}()
 
 	testcases := []struct {
@@ -275,10 +400,9 @@ func TestStringer(t *testing.T) {
 	}
 
 	b := new(bytes.Buffer)
-	oldlg := lg
-	lg = &stderrLogger{log.New(b, "", 0)}
+	oldlg := swapLogger(NewWriterLogger(b, TextFormatter))
 	defer func() {
-		lg = oldlg
+		swapLogger(oldlg)
 	}()
 
 	var v Level
@@ -291,3 +415,19 @@ func TestStringer(t *testing.T) {
 		t.Errorf("called want:1 got:%d", called)
 	}
 }
+
+func TestSetLoggerWhileLogging(t *testing.T) {
+	testLogger(t)
+	var v Level
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			v.I("msg")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		SetLogger(NewWriterLogger(new(bytes.Buffer), TextFormatter))
+	}
+	<-done
+}